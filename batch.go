@@ -0,0 +1,318 @@
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+
+// defaultBatchIdleTimeout is how long batch mode waits for more from the server, after all of
+// stdin has been sent, when it can neither wait for a prompt nor half-close the connection.
+const defaultBatchIdleTimeout = 1 * time.Second
+
+
+var (
+	errBatchClosedEarly   = errors.New("The connection was closed before all of the input was sent.")
+	errBatchPromptMissing = errors.New("The connection was closed before the prompt was received.")
+	errBatchPromptTimeout = errors.New("Timed out waiting for the prompt.")
+)
+
+
+// A batchChunk is what was read from the server; i.e., data and/or an error.
+type batchChunk struct {
+	p   []byte
+	err error
+}
+
+
+// callBatch is what StandardCaller does in batch mode.
+//
+// It sends all of 'stdin' to the other side, a line at a time (including a last line without
+// a trailing newline), while writing everything the other side sends to 'stdout'.
+//
+// If config.Prompt is set, then each line is only sent once the other side has sent something
+// matching it (since the line before was sent; or, for the first line, since connecting). Or,
+// if the other side sends nothing more (without that) for config.IdleTimeout, or a second if it
+// is not set, then the line is sent anyway. (For example, for a server that does not show a
+// prompt on connecting.) That way, an earlier prompt cannot be mistaken for the reply to the
+// last line.
+//
+// Once all of 'stdin' has been sent, it:
+//
+// • waits for the other side to send something matching config.Prompt (after the last line of
+// 'stdin' was sent), if it is set; else
+//
+// • waits until the other side has sent nothing for config.IdleTimeout, if it is set; else
+//
+// • half-closes the connection, and waits for the other side to close it.
+//
+// Either way, everything the other side sent is written to 'stdout' before it returns.
+func (caller internalStandardCaller) callBatch(stdin io.Reader, stdout io.Writer, w Writer, r Reader) error {
+
+	config := caller.config
+
+	done := make(chan struct{})
+	defer close(done)
+
+	received := make(chan batchChunk, 16)
+	go func() {
+		for {
+			var buffer [1024]byte
+			n, err := r.Read(buffer[:])
+
+			if 0 < n || nil != err {
+				select {
+				case received <- batchChunk{p:buffer[:n], err:err}:
+				case <-done:
+					return
+				}
+			}
+			if nil != err {
+				return
+			}
+		}
+	}()
+
+	// Before each line is sent, the sender says so on 'turn' (and whether it is the last
+	// line), and then waits on 'ready' to go ahead.
+	turn := make(chan bool)
+	ready := make(chan struct{})
+	before := func(last bool) error {
+		if nil == config.Prompt {
+			return nil
+		}
+
+		select {
+		case turn <- last:
+		case <-done:
+			return errBatchClosedEarly
+		}
+
+		select {
+		case <-ready:
+			return nil
+		case <-done:
+			return errBatchClosedEarly
+		}
+	}
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sendBatch(w, stdin, before)
+	}()
+
+	var output bytes.Buffer // what was received since the last line of stdin was sent; i.e., where the prompt is looked for.
+	var prompted bool       // whether 'output' matched the prompt.
+	var started bool        // whether any line of stdin was (about to be) sent.
+	var lastSent bool       // whether the last line of stdin was (about to be) sent.
+	var finished bool       // whether all of stdin was sent.
+
+	var waiting     bool // whether the next line of stdin is waiting for the prompt.
+	var waitingLast bool // whether that is the last line.
+
+	quietTimeout := config.IdleTimeout
+	if 0 >= quietTimeout {
+		quietTimeout = defaultBatchIdleTimeout
+	}
+	var quiet *time.Timer
+	var quieted <-chan time.Time
+	defer func() {
+		if nil != quiet {
+			quiet.Stop()
+		}
+	}()
+	waitQuiet := func() {
+		if nil != quiet {
+			quiet.Stop()
+		}
+		quiet = time.NewTimer(quietTimeout)
+		quieted = quiet.C
+	}
+
+	goAhead := func() {
+		if nil != quiet {
+			quiet.Stop()
+		}
+		quieted = nil
+
+		output.Reset()
+		prompted = false
+		started = true
+		lastSent = waitingLast
+		waiting = false
+
+		ready <- struct{}{}
+	}
+
+	var idle *time.Timer
+	var idleTimeout time.Duration
+	var timedOut <-chan time.Time
+
+	for {
+		select {
+		case err := <-sent:
+			if nil != err {
+				return err
+			}
+
+			finished = true
+			sent = nil
+
+			// If there was nothing to send, then any prompt will do.
+			if !started {
+				lastSent = true
+				if prompted {
+					return nil
+				}
+			}
+
+			idleTimeout = config.IdleTimeout
+			if nil == config.Prompt && 0 >= idleTimeout {
+				if err := closeWrite(w); nil != err {
+					idleTimeout = defaultBatchIdleTimeout
+				}
+			}
+			if 0 < idleTimeout {
+				idle = time.NewTimer(idleTimeout)
+				defer idle.Stop()
+				timedOut = idle.C
+			}
+
+		case last := <-turn:
+			waiting = true
+			waitingLast = last
+			if prompted {
+				goAhead()
+				continue
+			}
+			waitQuiet()
+
+		case <-quieted:
+			goAhead()
+
+		case chunk := <-received:
+			if 0 < len(chunk.p) {
+				if _, err := oi.LongWrite(stdout, chunk.p); nil != err {
+					return err
+				}
+			}
+
+			if nil != config.Prompt && 0 < len(chunk.p) && !prompted {
+				output.Write(chunk.p)
+				if config.Prompt.Match(output.Bytes()) {
+					if lastSent {
+						return nil
+					}
+					prompted = true
+				}
+			}
+
+			if waiting {
+				if prompted {
+					goAhead()
+				} else {
+					waitQuiet()
+				}
+			}
+
+			if finished {
+				if nil != idle {
+					if !idle.Stop() {
+						<-idle.C
+					}
+					idle.Reset(idleTimeout)
+				}
+			}
+
+			switch {
+			case nil == chunk.err:
+				// Nothing here.
+			case io.EOF != chunk.err:
+				return chunk.err
+			case !finished:
+				return errBatchClosedEarly
+			case nil != config.Prompt:
+				return errBatchPromptMissing
+			default:
+				return nil
+			}
+
+		case <-timedOut:
+			if nil != config.Prompt {
+				return errBatchPromptTimeout
+			}
+			return nil
+		}
+	}
+}
+
+
+// sendBatch sends all of 'stdin' to 'w', a line at a time, with each line ending in CR LF.
+//
+// It calls 'before' just before it sends each line; with whether it is the last line. If that
+// returns an error, then sendBatch stops, and returns it.
+func sendBatch(w Writer, stdin io.Reader, before func(last bool) error) error {
+
+	reader := bufio.NewReader(stdin)
+
+	var buffer bytes.Buffer
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if nil != err && io.EOF != err {
+			return err
+		}
+
+		// Look ahead, to see whether this is the last line.
+		if nil == err {
+			if _, err = reader.Peek(1); nil != err && io.EOF != err {
+				return err
+			}
+		}
+
+		if 0 < len(line) {
+			if err := before(io.EOF == err); nil != err {
+				return err
+			}
+
+			line = bytes.TrimSuffix(line, []byte{'\n'})
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+
+			buffer.Reset()
+			buffer.Write(line)
+			buffer.WriteString("\r\n")
+
+			p := buffer.Bytes()
+
+			n, err := oi.LongWrite(w, p)
+			if nil != err {
+				return err
+			}
+			if expected, actual := int64(len(p)), n; expected != actual {
+				return fmt.Errorf("Transmission problem: tried sending %d bytes, but actually only sent %d bytes.", expected, actual)
+			}
+		}
+
+		if io.EOF == err {
+			return nil
+		}
+	}
+}
+
+
+// closeWrite half-closes 'w', if it can be.
+func closeWrite(w Writer) error {
+	closer, ok := w.(interface{ CloseWrite() error })
+	if !ok {
+		return errCloseWriteNotSupported
+	}
+
+	return closer.CloseWrite()
+}
@@ -0,0 +1,221 @@
+package telnet
+
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+// A batchTestConn is a fake connection for batch mode, where the other side echoes back
+// whatever is sent to it.
+type batchTestConn struct {
+	sent bytes.Buffer
+	pw   *io.PipeWriter
+}
+
+func (conn *batchTestConn) Write(p []byte) (int, error) {
+	conn.sent.Write(p)
+	return conn.pw.Write(p)
+}
+
+
+// A batchTestHalfCloseConn is a batchTestConn that can be half-closed; and then the other side
+// says "bye", and closes the connection.
+type batchTestHalfCloseConn struct {
+	batchTestConn
+}
+
+func (conn *batchTestHalfCloseConn) CloseWrite() error {
+	conn.pw.Write([]byte("bye"))
+	return conn.pw.Close()
+}
+
+
+// A batchTestShellConn is a fake connection for batch mode, where the other side is a (slow)
+// shell: it shows a prompt on connecting, and then echoes back each line followed by a prompt.
+type batchTestShellConn struct {
+	sent    bytes.Buffer
+	replies chan []byte
+}
+
+func newBatchTestShellConn(pw *io.PipeWriter) *batchTestShellConn {
+	conn := batchTestShellConn{
+		replies:make(chan []byte, 16),
+	}
+
+	go func() {
+		pw.Write([]byte("$ "))
+		for reply := range conn.replies {
+			time.Sleep(10 * time.Millisecond)
+			pw.Write(reply)
+		}
+	}()
+
+	return &conn
+}
+
+func (conn *batchTestShellConn) Write(p []byte) (int, error) {
+	conn.sent.Write(p)
+	conn.replies <- append(append([]byte(nil), p...), "$ "...)
+	return len(p), nil
+}
+
+
+func TestStandardCallerBatch(t *testing.T) {
+
+	tests := []struct{
+		Stdin       string
+		HalfClose   bool
+		IdleTimeout time.Duration
+		Prompt      *regexp.Regexp
+
+		ExpectedSent   string
+		ExpectedStdout string
+		ExpectedErr    error
+	}{
+		{
+			Stdin:          "apple\nbanana\ncherry",
+			HalfClose:      true,
+			ExpectedSent:   "apple\r\nbanana\r\ncherry\r\n",
+			ExpectedStdout: "apple\r\nbanana\r\ncherry\r\nbye",
+		},
+		{
+			Stdin:          "apple\r\nbanana\n",
+			HalfClose:      true,
+			ExpectedSent:   "apple\r\nbanana\r\n",
+			ExpectedStdout: "apple\r\nbanana\r\nbye",
+		},
+		{
+			Stdin:          "",
+			HalfClose:      true,
+			ExpectedSent:   "",
+			ExpectedStdout: "bye",
+		},
+
+
+
+		{
+			Stdin:          "apple\nbanana",
+			IdleTimeout:    20 * time.Millisecond,
+			ExpectedSent:   "apple\r\nbanana\r\n",
+			ExpectedStdout: "apple\r\nbanana\r\n",
+		},
+
+
+
+		{
+			Stdin:          "apple\nbanana\n",
+			Prompt:         regexp.MustCompile(`banana\r\n$`),
+			ExpectedSent:   "apple\r\nbanana\r\n",
+			ExpectedStdout: "apple\r\nbanana\r\n",
+		},
+		{
+			Stdin:          "apple\nbanana\n",
+			IdleTimeout:    20 * time.Millisecond,
+			Prompt:         regexp.MustCompile(`\$ `),
+			ExpectedSent:   "apple\r\nbanana\r\n",
+			ExpectedStdout: "apple\r\nbanana\r\n",
+			ExpectedErr:    errBatchPromptTimeout,
+		},
+		{
+			Stdin:          "apple\n",
+			HalfClose:      true,
+			Prompt:         regexp.MustCompile(`cherry`),
+			ExpectedSent:   "apple\r\n",
+			ExpectedStdout: "apple\r\n",
+			ExpectedErr:    errBatchPromptTimeout,
+			IdleTimeout:    20 * time.Millisecond,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		pr, pw := io.Pipe()
+
+		var w Writer
+		var conn *batchTestConn
+		if test.HalfClose {
+			halfCloseConn := &batchTestHalfCloseConn{batchTestConn{pw:pw}}
+			conn = &halfCloseConn.batchTestConn
+			w = halfCloseConn
+		} else {
+			conn = &batchTestConn{pw:pw}
+			w = conn
+		}
+
+		var stdout bytes.Buffer
+
+		caller := internalStandardCaller{config:StandardCallerConfig{Batch:true, IdleTimeout:test.IdleTimeout, Prompt:test.Prompt}}
+		err := caller.callBatch(strings.NewReader(test.Stdin), &stdout, w, pr)
+		pw.Close()
+
+		if expected, actual := test.ExpectedErr, err; expected != actual {
+			t.Errorf("For test #%d, expected error %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedSent, conn.sent.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q to be sent, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedStdout, stdout.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q on stdout, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestStandardCallerBatchClosedEarly(t *testing.T) {
+
+	stdin, _ := io.Pipe() // never ends.
+
+	var stdout bytes.Buffer
+
+	caller := internalStandardCaller{config:StandardCallerConfig{Batch:true}}
+	err := caller.callBatch(stdin, &stdout, &bytes.Buffer{}, strings.NewReader("bye"))
+
+	if expected, actual := errBatchClosedEarly, err; expected != actual {
+		t.Errorf("Expected error %v, but actually got: (%T) %v", expected, actual, actual)
+		return
+	}
+
+	if expected, actual := "bye", stdout.String(); expected != actual {
+		t.Errorf("Expected %q on stdout, but actually got %q.", expected, actual)
+		return
+	}
+}
+
+
+func TestStandardCallerBatchPromptOnConnect(t *testing.T) {
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	conn := newBatchTestShellConn(pw)
+	defer close(conn.replies)
+
+	var stdout bytes.Buffer
+
+	caller := internalStandardCaller{config:StandardCallerConfig{Batch:true, Prompt:regexp.MustCompile(`\$ $`)}}
+	if err := caller.callBatch(strings.NewReader("apple\nbanana\ncherry\n"), &stdout, conn, pr); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "apple\r\nbanana\r\ncherry\r\n", conn.sent.String(); expected != actual {
+		t.Errorf("Expected %q to be sent, but actually got %q.", expected, actual)
+	}
+
+	// I.e., it did not stop at an earlier prompt.
+	if expected, actual := "$ apple\r\n$ banana\r\n$ cherry\r\n$ ", stdout.String(); expected != actual {
+		t.Errorf("Expected %q on stdout, but actually got %q.", expected, actual)
+	}
+}
@@ -16,3 +16,47 @@ package telnet
 type Caller interface {
 	CallTELNET(Context, Writer, Reader)
 }
+
+
+// An ErrorCaller is a Caller that can also report an error.
+//
+// If the Caller given to a Client is an ErrorCaller, then the Client calls its
+// CallTELNETWithError method (rather than its CallTELNET method), and the error it returns
+// is returned by Client.Call (and by DialAndCall, DialToAndCall, etc).
+//
+// CallerFunc is an easy way of making an ErrorCaller.
+type ErrorCaller interface {
+	Caller
+	CallTELNETWithError(Context, Writer, Reader) error
+}
+
+
+// The CallerFunc type is an adapter to allow the use of ordinary funcs as TELNET (or TELNETS) callers.
+//
+// For example:
+//
+//	caller := telnet.CallerFunc(func(ctx telnet.Context, w telnet.Writer, r telnet.Reader) error {
+//		if _, err := w.Write([]byte("show version\r\n")); nil != err {
+//			return err
+//		}
+//	
+//		//@TODO: Read the response.
+//	
+//		return nil
+//	})
+//	
+//	err := telnet.DialToAndCall("example.net:23", caller)
+type CallerFunc func(Context, Writer, Reader) error
+
+
+// CallTELNET calls fn(ctx, w, r), ignoring the error it returns. (It makes CallerFunc fit the
+// Caller interface.)
+func (fn CallerFunc) CallTELNET(ctx Context, w Writer, r Reader) {
+	fn(ctx, w, r)
+}
+
+
+// CallTELNETWithError calls fn(ctx, w, r).
+func (fn CallerFunc) CallTELNETWithError(ctx Context, w Writer, r Reader) error {
+	return fn(ctx, w, r)
+}
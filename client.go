@@ -2,65 +2,105 @@ package telnet
 
 
 import (
+	"context"
 	"crypto/tls"
+	"time"
 )
 
 
 func DialAndCall(caller Caller) error {
-	conn, err := Dial()
-	if nil != err {
-		return err
-	}
-
-	client := &Client{Caller:caller}
-
-	return client.Call(conn)
+	return DialToAndCall("", caller)
 }
 
 
 func DialToAndCall(srvAddr string, caller Caller) error {
-	conn, err := DialTo(srvAddr)
-	if nil != err {
-		return err
-	}
-
-	client := &Client{Caller:caller}
+	var dialer Dialer
 
-	return client.Call(conn)
+	return dialAndCall(&dialer, srvAddr, caller)
 }
 
 
 func DialAndCallTLS(caller Caller, tlsConfig *tls.Config) error {
-	conn, err := DialTLS(tlsConfig)
-	if nil != err {
-		return err
+	return DialToAndCallTLS("", caller, tlsConfig)
+}
+
+func DialToAndCallTLS(srvAddr string, caller Caller, tlsConfig *tls.Config) error {
+	if nil == tlsConfig {
+		tlsConfig = &tls.Config{}
 	}
 
-	client := &Client{Caller:caller}
+	dialer := Dialer{
+		TLSConfig: tlsConfig,
+	}
 
-	return client.Call(conn)
+	return dialAndCall(&dialer, srvAddr, caller)
 }
 
-func DialToAndCallTLS(srvAddr string, caller Caller, tlsConfig *tls.Config) error {
-	conn, err := DialToTLS(srvAddr, tlsConfig)
-	if nil != err {
-		return err
-	}
 
+func dialAndCall(dialer *Dialer, srvAddr string, caller Caller) error {
 	client := &Client{Caller:caller}
 
-	return client.Call(conn)
+	return client.DialAndCall(context.Background(), dialer, "tcp", srvAddr)
 }
 
 
 type Client struct {
 	Caller Caller
 
+	Lenient bool // if true, protocol errors from the server are logged and skipped over, rather than returned by Read (as a *ProtocolError).
+
 	Logger Logger
+
+	// Username and Password, if set, are used to log in automatically, on each new connection.
+	// (Before OnConnect and the Caller are called.)
+	//
+	// The user name is sent with NEW-ENVIRON (if the server asks for it), and common login and
+	// password prompts (such as "login:" and "Password:") are answered. If the server says the
+	// login failed (for example, "Login incorrect"), then the session ends with a *LoginError.
+	Username string
+	Password string
+
+	// Credentials, if not nil, is called (on each new connection) to get the user name and
+	// password, rather than using Username and Password.
+	Credentials func() (username string, password string, err error)
+
+	// LoginTimeout is how long to wait for each login (or password) prompt; 10 seconds if zero.
+	// If no prompt shows up in time, then it is assumed that no login is needed.
+	LoginTimeout time.Duration
+
+	// OnConnect, if not nil, is called on each new connection, before the Caller. (For
+	// example, to log in.) If it returns an error, then the Caller is not called, and the
+	// session ends with that error.
+	OnConnect func(Context, Writer, Reader) error
+
+	// Reconnect, if not nil, makes the Client reconnect (with backoff) when the connection
+	// drops. The Caller (and OnConnect) is called again on each new connection.
+	//
+	// Since reconnecting requires knowing how to dial, this is only used by Client.DialAndCall;
+	// Client.Call and Client.CallSession ignore it.
+	Reconnect *ReconnectPolicy
+
+	// Transcript, if not nil, logs each session (including logging in). (See Transcript.)
+	Transcript *Transcript
 }
 
 
+// Call runs the Client's Caller over 'conn', and then closes 'conn'.
+//
+// If the Caller is an ErrorCaller, then the error it returns is returned. Else, errors seen
+// reading from or writing to 'conn' (other than the other side closing the connection) are
+// returned.
+//
+// Call returns nil if the session ended normally. Use CallSession to also find out why
+// the session ended.
 func (client *Client) Call(conn *Conn) error {
+	return client.CallSession(conn).Err
+}
+
+
+// CallSession is like Call, but returns a SessionResult that says why the session ended
+// (along with any error).
+func (client *Client) CallSession(conn *Conn) SessionResult {
 
 	logger := client.logger()
 
@@ -72,16 +112,48 @@ func (client *Client) Call(conn *Conn) error {
 	}
 
 
-	var ctx Context = NewContext().InjectLogger(logger)
+	// Only override what the Conn was given at construction (see NewConn) if the
+	// Client was actually configured.
+	if nil != client.Logger || nil == conn.ctx.Logger() {
+		conn.ctx.InjectLogger(logger)
+	}
+	if client.Lenient {
+		conn.dataReader.lenient = true
+	}
+	if nil != client.Transcript {
+		conn.transcript = client.Transcript
+	}
+
+	var ctx Context = conn.ctx
 
 	var w Writer = conn
 	var r Reader = conn
 
-	caller.CallTELNET(ctx, w, r)
+	reported := true
+
+	err := client.login(conn)
+	if nil != err {
+		logger.Debugf("Login failed: %v", err)
+	} else if nil != client.OnConnect {
+		err = client.OnConnect(ctx, w, r)
+	}
+	if nil != err {
+		logger.Debugf("Session ended before the Caller was called: %v", err)
+	} else if errorCaller, ok := caller.(ErrorCaller); ok {
+		err = errorCaller.CallTELNETWithError(ctx, w, r)
+	} else {
+		caller.CallTELNET(ctx, w, r)
+		reported = false
+	}
+
+	readErr, writeErr := conn.errors()
+	result := newSessionResult(err, reported, readErr, writeErr)
+	logger.Debugf("Session ended: %v (%v)", result.End, result.Err)
+
 	conn.Close()
 
 
-	return nil
+	return result
 }
 
 
@@ -95,6 +167,7 @@ func (client *Client) logger() Logger {
 }
 
 
+// SetAuth sets the user name to log in with. (See the Client's Username field.)
 func (client *Client) SetAuth(username string) {
-//@TODO: #################################################
+	client.Username = username
 }
@@ -0,0 +1,336 @@
+/*
+Command telnet is a TELNET (and TELNETS) client, in the style of the classic telnet program.
+
+Usage:
+
+	telnet [flags] host [port]
+	telnet [flags] telnet://[user@]host[:port]/
+	telnet [flags] telnets://[user@]host[:port]/
+
+The port is 23 (or 992 with -tls) if it is not given.
+
+When stdin is a terminal, telnet is interactive: typing the escape character (Ctrl-] by default)
+drops into a "telnet>" command prompt; type "help" there for the commands.
+
+When stdin is not a terminal (or with -batch), telnet runs in batch mode: it sends all of stdin to
+the server, a line at a time, and then waits for the server to finish (for the -prompt, if it is
+given; else for the connection to go idle for -idle, if it is given; else for the server to close
+the connection). With -prompt, each line is only sent once the server has shown the prompt (or has
+gone quiet). Everything the server sent is written to stdout.
+
+Commands in ~/.telnetrc (or the -rc file) are done at the start of each interactive session. Each
+line that starts with a host name (or "DEFAULT", for every host) starts an entry for that host; the
+rest of that line, and each following line that starts with a space or a tab, is a "telnet>"
+command. Lines that start with a "#" are comments. For example:
+
+	DEFAULT toggle crlf
+	router.example.net
+		mode character
+
+The exit status is 0 if the session ended normally, 1 if there was an error (such as the server not
+showing the -prompt in batch mode), and 2 if the command line was wrong.
+
+Flags:
+
+	-l user        send 'user' to the server as the NEW-ENVIRON "USER" variable, if it asks for it
+	-tls           use TELNETS (TELNET over TLS)
+	-cafile file   with -tls, trust the CA certificates in 'file' (PEM format)
+	-insecure      with -tls, do not verify the server's certificate
+	-8             ask for an 8-bit data path (the TRANSMIT-BINARY option)
+	-e char        the escape character; such as "^]" (the default), "^A", or "none"
+	-E             no escape character
+	-edit          edit lines locally (with history in ~/.telnet_history/) while in line mode
+	-rc file       the telnetrc file; ~/.telnetrc by default ("" for none)
+	-batch         batch mode, even if stdin is a terminal
+	-prompt regexp in batch mode, what the server shows when it is done
+	-idle duration in batch mode, how long the server can be idle before giving up waiting
+	-timeout dur   how long to wait for the connection to be made
+	-log file      log the session to 'file'
+	-logformat fmt the -log format: "text" (the default) or "timestamped" (see the replay flag)
+	-replay file   play back a session logged with -logformat timestamped, and exit
+*/
+package main
+
+
+import (
+	"github.com/reiver/go-telnet"
+
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("telnet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, "usage: telnet [flags] host [port]\n       telnet [flags] telnet://[user@]host[:port]/\n\n")
+		flags.PrintDefaults()
+	}
+
+	var (
+		user       = flags.String("l", "", "send `user` to the server as the NEW-ENVIRON \"USER\" variable")
+		useTLS     = flags.Bool("tls", false, "use TELNETS (TELNET over TLS)")
+		caFile     = flags.String("cafile", "", "with -tls, trust the CA certificates in `file` (PEM format)")
+		insecure   = flags.Bool("insecure", false, "with -tls, do not verify the server's certificate")
+		binary     = flags.Bool("8", false, "ask for an 8-bit data path (the TRANSMIT-BINARY option)")
+		escape     = flags.String("e", "^]", "the escape `char`; such as \"^]\", \"^A\", or \"none\"")
+		noEscape   = flags.Bool("E", false, "no escape character")
+		edit       = flags.Bool("edit", false, "edit lines locally (with history) while in line mode")
+		rcFile     = flags.String("rc", defaultPath(".telnetrc"), "the telnetrc `file` (\"\" for none)")
+		batch      = flags.Bool("batch", false, "batch mode, even if stdin is a terminal")
+		prompt     = flags.String("prompt", "", "in batch mode, what the server shows when it is done (a `regexp`)")
+		idle       = flags.Duration("idle", 0, "in batch mode, how long the server can be idle before giving up waiting")
+		timeout    = flags.Duration("timeout", 0, "how long to wait for the connection to be made")
+		logFile    = flags.String("log", "", "log the session to `file`")
+		logFormat  = flags.String("logformat", "text", "the -log `format`: \"text\" or \"timestamped\"")
+		replayFile = flags.String("replay", "", "play back a session logged (to `file`) with -logformat timestamped, and exit")
+	)
+
+	if err := flags.Parse(args); nil != err {
+		return 2
+	}
+
+	if "" != *replayFile {
+		if err := replay(*replayFile, stdout); nil != err {
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() < 1 || 2 < flags.NArg() {
+		flags.Usage()
+		return 2
+	}
+
+
+	host := flags.Arg(0)
+	var port string
+	if 2 == flags.NArg() {
+		port       = flags.Arg(1)
+	}
+
+	if strings.Contains(host, "://") {
+		if "" != port {
+			flags.Usage()
+			return 2
+		}
+
+		u, err := telnet.ParseURL(host)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 2
+		}
+
+		host, port = u.Host, u.Port
+		*useTLS = *useTLS || u.TLS()
+		if "" == *user {
+			*user = u.User
+		}
+	}
+
+	if "" == port {
+		if *useTLS {
+			port = "992"
+		} else {
+			port = "23"
+		}
+	}
+
+
+	escapeChar, noEscapeChar, err := parseEscape(*escape)
+	if nil != err {
+		fmt.Fprintf(stderr, "telnet: %v\n", err)
+		return 2
+	}
+
+	config := telnet.StandardCallerConfig{
+		Escape:      escapeChar,
+		NoEscape:    noEscapeChar || *noEscape,
+		LineEditing: *edit,
+		IdleTimeout: *idle,
+	}
+
+	if *edit {
+		config.HistoryDir = defaultPath(".telnet_history")
+	}
+
+	config.Batch = *batch || !isTerminal(os.Stdin)
+	if "" != *prompt {
+		config.Prompt, err = regexp.Compile(*prompt)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnet: bad -prompt: %v\n", err)
+			return 2
+		}
+	}
+
+	if "" != *rcFile && !config.Batch {
+		commands, err := loadTelnetrc(*rcFile, host)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 1
+		}
+		config.Commands = commands
+	}
+
+
+	var transcript *telnet.Transcript
+	if "" != *logFile {
+		var format telnet.TranscriptFormat
+		switch *logFormat {
+		case "text":
+			format = telnet.TranscriptText
+		case "timestamped":
+			format = telnet.TranscriptTimestamped
+		default:
+			fmt.Fprintf(stderr, "telnet: bad -logformat %q; must be \"text\" or \"timestamped\"\n", *logFormat)
+			return 2
+		}
+
+		file, err := os.OpenFile(*logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		transcript = &telnet.Transcript{
+			Writer:          file,
+			Format:          format,
+			MaskHiddenInput: true,
+		}
+	}
+
+
+	dialer := telnet.Dialer{
+		Timeout: *timeout,
+	}
+
+	if *useTLS {
+		dialer.TLSConfig, err = tlsConfig(*caFile, *insecure)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 1
+		}
+	}
+
+	addr := net.JoinHostPort(host, port)
+
+	if !config.Batch {
+		fmt.Fprintf(stdout, "Trying %s...\n", addr)
+	}
+
+	conn, err := dialer.DialContext(context.Background(), "tcp", addr)
+	if nil != err {
+		fmt.Fprintf(stderr, "telnet: Unable to connect to remote host: %v\n", err)
+		return 1
+	}
+
+	if "" != *user {
+		conn.Context().InjectUser(*user)
+	}
+
+	if *binary {
+		if err := conn.RequestBinary(); nil != err {
+			conn.Close()
+			fmt.Fprintf(stderr, "telnet: %v\n", err)
+			return 1
+		}
+	}
+
+	if !config.Batch {
+		fmt.Fprintf(stdout, "Connected to %s.\n", host)
+		if config.NoEscape {
+			fmt.Fprint(stdout, "No escape character.\n")
+		} else {
+			fmt.Fprintf(stdout, "Escape character is '%s'.\n", visibleEscape(config.Escape))
+		}
+	}
+
+	client := telnet.Client{
+		Caller:     telnet.NewStandardCaller(config),
+		Transcript: transcript,
+	}
+
+	result := client.CallSession(conn)
+
+	if !config.Batch && telnet.SessionEndRemoteClose == result.End {
+		fmt.Fprint(stdout, "Connection closed by foreign host.\n")
+	}
+
+	if nil != transcript {
+		if err := transcript.Err(); nil != err {
+			fmt.Fprintf(stderr, "telnet: could not write the log: %v\n", err)
+			return 1
+		}
+	}
+
+	if nil != result.Err {
+		fmt.Fprintf(stderr, "telnet: %v\n", result.Err)
+		return 1
+	}
+
+	return 0
+}
+
+
+// defaultPath returns the path to 'name' in the home directory; or "" if there is no home directory.
+func defaultPath(name string) string {
+	home, err := os.UserHomeDir()
+	if nil != err {
+		return ""
+	}
+
+	return filepath.Join(home, name)
+}
+
+
+func tlsConfig(caFile string, insecure bool) (*tls.Config, error) {
+	config := tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+
+	if "" != caFile {
+		pem, err := ioutil.ReadFile(caFile)
+		if nil != err {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in " + caFile + ".")
+		}
+		config.RootCAs = pool
+	}
+
+	return &config, nil
+}
+
+
+func replay(path string, stdout io.Writer) error {
+	file, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	return telnet.ReplayTranscript(stdout, file, 1)
+}
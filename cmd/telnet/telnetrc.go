@@ -0,0 +1,116 @@
+package main
+
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+
+// loadTelnetrc returns the commands in the telnetrc file 'path' for 'host'. (A missing file just
+// has no commands.)
+func loadTelnetrc(path string, host string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseTelnetrc(file, host)
+}
+
+
+// parseTelnetrc returns the commands in the telnetrc file 'r' for 'host'; i.e., those of the
+// "DEFAULT" entries, and then those of the entries for 'host'.
+//
+// Each line that starts with a host name (or "DEFAULT") starts an entry; the rest of that line,
+// and each following line that starts with a space or a tab, is a command. Blank lines, and lines
+// that start with a "#", are skipped.
+func parseTelnetrc(r io.Reader, host string) ([]string, error) {
+
+	var defaults []string
+	var commands []string
+
+	var entry *[]string // the commands of the current entry; nil if it is for some other host.
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		trimmed := strings.TrimSpace(line)
+		if "" == trimmed || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if ' ' != line[0] && '\t' != line[0] {
+			fields := strings.SplitN(trimmed, " ", 2)
+			fields = strings.SplitN(fields[0], "\t", 2)
+			name := fields[0]
+
+			switch {
+			case "DEFAULT" == name:
+				entry = &defaults
+			case strings.EqualFold(host, name):
+				entry = &commands
+			default:
+				entry = nil
+			}
+
+			trimmed = strings.TrimSpace(trimmed[len(name):])
+			if "" == trimmed {
+				continue
+			}
+		}
+
+		if nil != entry {
+			*entry = append(*entry, trimmed)
+		}
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+
+	return append(defaults, commands...), nil
+}
+
+
+// parseEscape parses an escape character, such as given with the -e flag. It can be a single
+// character (such as "~"), a control character in caret notation (such as "^]" or "^A"), or "none"
+// (or "", or "^@") for no escape character.
+func parseEscape(s string) (escape byte, none bool, err error) {
+	switch {
+	case "" == s || "none" == s || "^@" == s:
+		return 0, true, nil
+	case 1 == len(s):
+		return s[0], false, nil
+	case 2 == len(s) && '^' == s[0] && '?' == s[1]:
+		return 0x7F, false, nil
+	case 2 == len(s) && '^' == s[0] && '@' <= s[1] && s[1] <= '_':
+		return s[1] - '@', false, nil
+	case 2 == len(s) && '^' == s[0] && 'a' <= s[1] && s[1] <= 'z':
+		return s[1] - 'a' + 1, false, nil
+	default:
+		return 0, false, fmt.Errorf("bad escape character %q", s)
+	}
+}
+
+
+// visibleEscape returns the escape character in a form that can be shown; e.g., "^]" for Ctrl-].
+func visibleEscape(b byte) string {
+	switch {
+	case 0 == b:
+		return "^]"
+	case b < 0x20:
+		return "^" + string(rune(b + 0x40))
+	case 0x7F == b:
+		return "^?"
+	default:
+		return string(rune(b))
+	}
+}
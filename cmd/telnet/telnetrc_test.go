@@ -0,0 +1,112 @@
+package main
+
+
+import (
+	"reflect"
+	"strings"
+
+	"testing"
+)
+
+
+func TestParseTelnetrc(t *testing.T) {
+
+	tests := []struct{
+		Telnetrc string
+		Host     string
+		Expected []string
+	}{
+		{
+			Telnetrc: "",
+			Host:     "example.net",
+			Expected: nil,
+		},
+		{
+			Telnetrc: "example.net mode character\n",
+			Host:     "example.net",
+			Expected: []string{"mode character"},
+		},
+		{
+			Telnetrc: "example.net\n\tmode character\n  toggle crlf\n",
+			Host:     "EXAMPLE.net",
+			Expected: []string{"mode character", "toggle crlf"},
+		},
+		{
+			Telnetrc: "other.net mode line\n\ttoggle crlf\nexample.net\tmode character\n",
+			Host:     "example.net",
+			Expected: []string{"mode character"},
+		},
+		{
+			Telnetrc: "# comment\nexample.net\n\n\t# comment\n\tmode character\nDEFAULT toggle crlf\n",
+			Host:     "example.net",
+			Expected: []string{"toggle crlf", "mode character"},
+		},
+		{
+			Telnetrc: "DEFAULT\n\tstatus\nexample.net mode character\n",
+			Host:     "other.net",
+			Expected: []string{"status"},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		commands, err := parseTelnetrc(strings.NewReader(test.Telnetrc), test.Host)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, commands; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestParseEscape(t *testing.T) {
+
+	tests := []struct{
+		Escape       string
+		Expected     byte
+		ExpectedNone bool
+		ExpectedErr  bool
+	}{
+		{Escape: "^]",   Expected: 0x1D},
+		{Escape: "^A",   Expected: 0x01},
+		{Escape: "^a",   Expected: 0x01},
+		{Escape: "^?",   Expected: 0x7F},
+		{Escape: "~",    Expected: '~'},
+		{Escape: "none", ExpectedNone: true},
+		{Escape: "",     ExpectedNone: true},
+		{Escape: "^@",   ExpectedNone: true},
+		{Escape: "^^^",  ExpectedErr: true},
+		{Escape: "ab",   ExpectedErr: true},
+	}
+
+
+	for testNumber, test := range tests {
+
+		escape, none, err := parseEscape(test.Escape)
+		if test.ExpectedErr {
+			if nil == err {
+				t.Errorf("For test #%d, expected an error, but did not actually get one.", testNumber)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, escape; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := test.ExpectedNone, none; expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
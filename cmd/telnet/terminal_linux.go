@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package main
+
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+
+// isTerminal reports whether 'file' is a terminal. (Asking for its terminal settings, rather than
+// checking for a character device; which /dev/null is too.)
+func isTerminal(file *os.File) bool {
+	var termios syscall.Termios
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return 0 == errno
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package main
+
+
+import (
+	"os"
+
+	"testing"
+)
+
+
+func TestIsTerminalDevNull(t *testing.T) {
+
+	file, err := os.Open(os.DevNull)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer file.Close()
+
+	if isTerminal(file) {
+		t.Errorf("Expected %s to not be a terminal.", os.DevNull)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+
+import (
+	"os"
+)
+
+
+// isTerminal reports whether 'file' is a terminal (or at least a character device).
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if nil != err {
+		return false
+	}
+
+	return 0 != info.Mode() & os.ModeCharDevice
+}
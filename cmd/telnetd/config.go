@@ -0,0 +1,133 @@
+package main
+
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+
+// A setting is a line of the config file; i.e., a flag name and its value.
+type setting struct {
+	Line  int
+	Name  string
+	Value string
+}
+
+
+// loadConfig applies the settings in the config file 'path' to 'flags', for each flag that was
+// not set on the command line.
+func loadConfig(path string, flags *flag.FlagSet) error {
+	file, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	settings, err := parseConfig(file)
+	if nil != err {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	if err := applyConfig(flags, settings); nil != err {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	return nil
+}
+
+
+// parseConfig returns the settings in the config file 'r'.
+//
+// Each line is a flag name (without the "-"), and its value; such as:
+//
+//	listen :2323
+//	idle   30m
+//
+// A flag that does not take a value (such as "syslog") can be on a line by itself, which is
+// the same as "syslog true". Blank lines, and lines that start with a "#", are skipped.
+func parseConfig(r io.Reader) ([]setting, error) {
+
+	var settings []setting
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value := line, ""
+		if i := strings.IndexAny(line, " \t"); 0 <= i {
+			name, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		name = strings.TrimLeft(name, "-")
+
+		settings = append(settings, setting{
+			Line:  number,
+			Name:  name,
+			Value: value,
+		})
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+
+// applyConfig sets the flags in 'settings', except for those that were already set on the
+// command line. (So the command line overrides the config file.)
+func applyConfig(flags *flag.FlagSet, settings []setting) error {
+
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for _, s := range settings {
+		f := flags.Lookup(s.Name)
+		if nil == f || "config" == s.Name {
+			return fmt.Errorf("line %d: unknown setting %q", s.Line, s.Name)
+		}
+		if explicit[s.Name] {
+			continue
+		}
+
+		value := s.Value
+		if "" == value && isBoolFlag(f) {
+			value = "true"
+		}
+
+		if err := flags.Set(s.Name, value); nil != err {
+			return fmt.Errorf("line %d: bad %s: %v", s.Line, s.Name, err)
+		}
+	}
+
+	return nil
+}
+
+
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && boolFlag.IsBoolFlag()
+}
+
+
+// A stringsFlag is a flag that can be given more than once; such as -listen.
+type stringsFlag []string
+
+func (values *stringsFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *stringsFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
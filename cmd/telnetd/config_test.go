@@ -0,0 +1,144 @@
+package main
+
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+func TestParseConfig(t *testing.T) {
+
+	tests := []struct{
+		Config   string
+		Expected []setting
+	}{
+		{
+			Config:   "",
+			Expected: nil,
+		},
+		{
+			Config:   "listen :2323\n",
+			Expected: []setting{{Line:1, Name:"listen", Value:":2323"}},
+		},
+		{
+			Config:   "# comment\n\n  exec\t/bin/login  -p \nsyslog\n-idle 5m\n",
+			Expected: []setting{
+				{Line:3, Name:"exec",   Value:"/bin/login  -p"},
+				{Line:4, Name:"syslog", Value:""},
+				{Line:5, Name:"idle",   Value:"5m"},
+			},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		settings, err := parseConfig(strings.NewReader(test.Config))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, settings; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected %#v, but actually got %#v.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestApplyConfig(t *testing.T) {
+
+	tests := []struct{
+		Args   []string
+		Config string
+
+		ExpectedListen []string
+		ExpectedExec   string
+		ExpectedIdle   time.Duration
+		ExpectedSyslog bool
+		ExpectedErr    bool
+	}{
+		{
+			Config:         "listen :2323\nlisten :2424\nexec /bin/login -p\nidle 5m\nsyslog\n",
+			ExpectedListen: []string{":2323", ":2424"},
+			ExpectedExec:   "/bin/login -p",
+			ExpectedIdle:   5 * time.Minute,
+			ExpectedSyslog: true,
+		},
+		{
+			Args:           []string{"-listen", ":23", "-idle", "1m"},
+			Config:         "listen :2323\nidle 5m\nsyslog false\n",
+			ExpectedListen: []string{":23"},
+			ExpectedIdle:   1 * time.Minute,
+		},
+		{
+			Config:      "listne :2323\n",
+			ExpectedErr: true,
+		},
+		{
+			Config:      "idle soon\n",
+			ExpectedErr: true,
+		},
+		{
+			Config:      "config other.conf\n",
+			ExpectedErr: true,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		flags := flag.NewFlagSet("telnetd", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+
+		var listen stringsFlag
+		flags.Var(&listen, "listen", "")
+		flags.String("config", "", "")
+		command   := flags.String("exec", "", "")
+		idle      := flags.Duration("idle", 0, "")
+		useSyslog := flags.Bool("syslog", false, "")
+
+		if err := flags.Parse(test.Args); nil != err {
+			t.Errorf("For test #%d, did not expect an error parsing the args, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		settings, err := parseConfig(strings.NewReader(test.Config))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error parsing the config, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		err = applyConfig(flags, settings)
+		if test.ExpectedErr {
+			if nil == err {
+				t.Errorf("For test #%d, expected an error, but actually did not get one.", testNumber)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.ExpectedListen, []string(listen); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected listen %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedExec, *command; expected != actual {
+			t.Errorf("For test #%d, expected exec %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedIdle, *idle; expected != actual {
+			t.Errorf("For test #%d, expected idle %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedSyslog, *useSyslog; expected != actual {
+			t.Errorf("For test #%d, expected syslog %t, but actually got %t.", testNumber, expected, actual)
+		}
+	}
+}
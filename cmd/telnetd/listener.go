@@ -0,0 +1,198 @@
+package main
+
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+
+var errCloseWriteNotSupported = errors.New("CloseWrite not supported by the underlying connection.")
+
+
+// tooManyConnections is what a client is sent when it is turned away by a limitListener.
+const tooManyConnections = "Too many connections; try again later.\r\n"
+
+
+// limits are the limits that apply to all of the connections of all of the limitListeners.
+type limits struct {
+	logger      *logger
+	idleTimeout time.Duration // zero means no idle timeout.
+
+	slots chan struct{} // nil if there is no limit on the number of connections.
+
+	mutex sync.Mutex
+	conns map[*limitConn]struct{} // the connections that are open. (See closeAll.)
+}
+
+
+func newLimits(maxConnections int, idleTimeout time.Duration, logger *logger) *limits {
+	l := limits{
+		logger:      logger,
+		idleTimeout: idleTimeout,
+		conns:       map[*limitConn]struct{}{},
+	}
+
+	if 0 < maxConnections {
+		l.slots = make(chan struct{}, maxConnections)
+	}
+
+	return &l
+}
+
+
+// closeAll closes all of the connections that are open. (For example, when stopping.)
+func (l *limits) closeAll() {
+	l.mutex.Lock()
+	conns := make([]*limitConn, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	l.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+
+// A limitListener is a net.Listener that limits how many connections can be open at once (to
+// the size of limits.slots), logs each connection, keeps track of the open connections (so that
+// they can be closed when stopping), and (if limits.idleTimeout is not zero) closes connections that
+// have been idle that long.
+//
+// A connection that comes in while there are no free slots is closed, rather than left waiting.
+// If 'plain' is true (i.e., the connection is not TLS) then it is sent tooManyConnections first.
+type limitListener struct {
+	net.Listener
+
+	limits *limits
+	plain  bool
+}
+
+
+func (listener *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := listener.Listener.Accept()
+		if nil != err {
+			return nil, err
+		}
+
+		limits := listener.limits
+
+		if nil != limits.slots {
+			select {
+			case limits.slots <- struct{}{}:
+				// Got a slot.
+			default:
+				limits.logger.Warnf("Turned away connection from %s: too many connections.", conn.RemoteAddr())
+				if listener.plain {
+					conn.SetWriteDeadline(time.Now().Add(time.Second))
+					conn.Write([]byte(tooManyConnections))
+				}
+				conn.Close()
+				continue
+			}
+		}
+
+		limits.logger.Infof("Connection from %s.", conn.RemoteAddr())
+
+		limited := limitConn{
+			Conn:    conn,
+			limits:  limits,
+			started: time.Now(),
+		}
+		limits.mutex.Lock()
+		limits.conns[&limited] = struct{}{}
+		if 0 < limits.idleTimeout {
+			limited.idle = time.AfterFunc(limits.idleTimeout, limited.expire)
+		}
+		limits.mutex.Unlock()
+
+		return &limited, nil
+	}
+}
+
+
+// A limitConn is a connection from a limitListener.
+//
+// The idle timeout is kept with a timer (that closes the connection), rather than with deadlines;
+// so that it does not get in the way of any deadlines the handler sets.
+type limitConn struct {
+	net.Conn
+
+	limits  *limits
+	started time.Time
+	idle    *time.Timer // nil if there is no idle timeout.
+	once    sync.Once
+}
+
+
+// touch pushes back the idle timeout.
+func (conn *limitConn) touch() {
+	if nil != conn.idle {
+		conn.idle.Reset(conn.limits.idleTimeout)
+	}
+}
+
+
+// expire closes the connection, as it has been idle for too long.
+func (conn *limitConn) expire() {
+	conn.limits.logger.Infof("Connection from %s was idle for %v.", conn.RemoteAddr(), conn.limits.idleTimeout)
+	conn.Close()
+}
+
+
+func (conn *limitConn) Read(p []byte) (int, error) {
+	n, err := conn.Conn.Read(p)
+	if 0 < n {
+		conn.touch()
+	}
+	return n, err
+}
+
+
+func (conn *limitConn) Write(p []byte) (int, error) {
+	conn.touch()
+	n, err := conn.Conn.Write(p)
+	if 0 < n {
+		conn.touch()
+	}
+	return n, err
+}
+
+
+// CloseWrite half-closes the connection, if the underlying connection can be.
+func (conn *limitConn) CloseWrite() error {
+	closeWriter, ok := conn.Conn.(interface{ CloseWrite() error })
+	if !ok {
+		return errCloseWriteNotSupported
+	}
+
+	return closeWriter.CloseWrite()
+}
+
+
+// Close closes the connection, and frees its slot. (It can be called more than once.)
+func (conn *limitConn) Close() error {
+	err := conn.Conn.Close()
+
+	conn.once.Do(func() {
+		if nil != conn.idle {
+			conn.idle.Stop()
+		}
+
+		conn.limits.mutex.Lock()
+		delete(conn.limits.conns, conn)
+		conn.limits.mutex.Unlock()
+
+		conn.limits.logger.Infof("Connection from %s closed after %v.", conn.RemoteAddr(), time.Since(conn.started).Round(time.Second))
+		if nil != conn.limits.slots {
+			<-conn.limits.slots
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+
+import (
+	"io/ioutil"
+	"net"
+	"time"
+
+	"testing"
+)
+
+
+// listenerTestAccept returns both sides of a connection accepted by a limitListener with 'limits'.
+func listenerTestAccept(t *testing.T, limits *limits) (server net.Conn, client net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	listener := limitListener{Listener:l, limits:limits, plain:true}
+	defer listener.Close()
+
+	client, err = net.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	server, err = listener.Accept()
+	if nil != err {
+		client.Close()
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return server, client
+}
+
+
+func TestLimitConnKeepsDeadlines(t *testing.T) {
+
+	limits := newLimits(0, time.Minute, newStderrLogger(ioutil.Discard, false))
+
+	server, client := listenerTestAccept(t, limits)
+	defer server.Close()
+	defer client.Close()
+
+	// The idle timeout must not push back the handler's own deadline.
+	server.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := server.Read(make([]byte, 16))
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("Expected a timeout, but actually got: (%T) %v", err, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the read deadline to pass, but it did not.")
+	}
+}
+
+
+func TestLimitConnIdle(t *testing.T) {
+
+	limits := newLimits(0, 30 * time.Millisecond, newStderrLogger(ioutil.Discard, false))
+
+	server, client := listenerTestAccept(t, limits)
+	defer server.Close()
+	defer client.Close()
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := server.Read(make([]byte, 16))
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		if nil == err {
+			t.Errorf("Expected an error, but did not actually get one.")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the idle connection to be closed, but it was not.")
+	}
+}
+
+
+func TestLimitsCloseAll(t *testing.T) {
+
+	limits := newLimits(1, 0, newStderrLogger(ioutil.Discard, false))
+
+	server, client := listenerTestAccept(t, limits)
+	defer server.Close()
+	defer client.Close()
+
+	limits.closeAll()
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 16)); nil == err {
+		t.Errorf("Expected the connection to be closed, but it was not.")
+	}
+
+	if expected, actual := 0, len(limits.slots); expected != actual {
+		t.Errorf("Expected %d slots to be taken, but actually %d were.", expected, actual)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+
+// A logger is a telnet.Logger that writes to stderr or to syslog. (It also has Info, for the
+// messages about connections coming and going.)
+//
+// Debug messages are only written if 'debug' is true; and trace messages are never written.
+type logger struct {
+	info  func(string)
+	warn  func(string)
+	err   func(string)
+	debug func(string) // nil unless debugging.
+}
+
+
+// newStderrLogger returns a logger that writes to 'w' (i.e., stderr), a line at a time, with
+// a timestamp.
+func newStderrLogger(w io.Writer, debug bool) *logger {
+	l := log.New(w, "", log.LstdFlags)
+
+	prefixed := func(prefix string) func(string) {
+		return func(message string) {
+			l.Print(prefix + message)
+		}
+	}
+
+	result := logger{
+		info: prefixed(""),
+		warn: prefixed("warning: "),
+		err:  prefixed("error: "),
+	}
+	if debug {
+		result.debug = prefixed("debug: ")
+	}
+
+	return &result
+}
+
+
+func (l *logger) Infof(format string, v ...interface{}) {
+	l.info(fmt.Sprintf(format, v...))
+}
+
+
+func (l *logger) Debug(v ...interface{}) {
+	if nil != l.debug {
+		l.debug(fmt.Sprint(v...))
+	}
+}
+
+func (l *logger) Debugf(format string, v ...interface{}) {
+	if nil != l.debug {
+		l.debug(fmt.Sprintf(format, v...))
+	}
+}
+
+
+func (l *logger) Error(v ...interface{}) {
+	l.err(fmt.Sprint(v...))
+}
+
+func (l *logger) Errorf(format string, v ...interface{}) {
+	l.err(fmt.Sprintf(format, v...))
+}
+
+
+func (l *logger) Trace(...interface{}) {}
+func (l *logger) Tracef(string, ...interface{}) {}
+
+
+func (l *logger) Warn(v ...interface{}) {
+	l.warn(fmt.Sprint(v...))
+}
+
+func (l *logger) Warnf(format string, v ...interface{}) {
+	l.warn(fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,297 @@
+/*
+Command telnetd is a TELNET (and TELNETS) server.
+
+Usage:
+
+	telnetd [flags]
+
+For each connection, telnetd does one of:
+
+• serves a (telsh) shell; which is what it does by default;
+
+• runs a program on a pseudo-terminal (like a classic telnetd runs login), with -exec (on Linux
+only); or
+
+• connects the client to another host (over plain TCP), with -forward. If -forward is given more
+than once, then the client picks the host from a menu.
+
+Flags can also be given in a config file, with -config; flags given on the command line override
+those in the config file. Each line of the config file is a flag name (without the "-") and its
+value. Lines that start with a "#" are comments. For example:
+
+	# Serve a program over TELNETS, on two ports.
+	tls-listen :992
+	tls-listen :8992
+	cert       /etc/telnetd/cert.pem
+	key        /etc/telnetd/key.pem
+	exec       /usr/local/bin/console --safe
+	max-conns  50
+	idle       30m
+	syslog
+
+Flags:
+
+	-config file      read flags from 'file'
+	-listen addr      listen for TELNET at 'addr'; can be given more than once (":23" if neither
+	                  -listen nor -tls-listen is given)
+	-tls-listen addr  listen for TELNETS (TELNET over TLS) at 'addr'; can be given more than once
+	-cert file        the TLS certificate (PEM format), for -tls-listen
+	-key file         the TLS private key (PEM format), for -tls-listen
+	-exec command     run 'command' (split on spaces, into the program and its arguments) for each
+	                  connection
+	-dir dir          with -exec, run the program in 'dir'
+	-uid id           with -exec, run the program as the user 'id' (and -gid; both are needed)
+	-gid id           with -exec, run the program as the group 'id'
+	-forward addr     connect each client to the TCP address 'addr'; or, as "name=addr", to the host
+	                  called 'name' in the menu; can be given more than once
+	-max-conns n      allow at most 'n' connections at once (0 for no limit)
+	-idle duration    close connections that have been idle for 'duration' (0 for never)
+	-syslog           log to syslog, rather than to stderr
+	-debug            also log debugging messages
+
+telnetd runs until it gets SIGINT or SIGTERM (or one of the listeners fails). Then it closes all
+of the connections.
+*/
+package main
+
+
+import (
+	"github.com/reiver/go-telnet"
+	"github.com/reiver/go-telnet/telsh"
+
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+
+var (
+	errNoCertificate    = errors.New("-tls-listen needs -cert and -key.")
+	errExecAndForward   = errors.New("Only one of -exec and -forward can be given.")
+	errEmptyExecCommand = errors.New("-exec needs a command.")
+	errUIDAndGID        = errors.New("-uid and -gid must be given together.")
+)
+
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+
+func run(args []string, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("telnetd", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, "usage: telnetd [flags]\n\n")
+		flags.PrintDefaults()
+	}
+
+	var listen, tlsListen, forward stringsFlag
+	flags.Var(&listen, "listen", "listen for TELNET at `addr`; can be given more than once")
+	flags.Var(&tlsListen, "tls-listen", "listen for TELNETS at `addr`; can be given more than once")
+	flags.Var(&forward, "forward", "connect each client to the TCP address `addr` (or \"name=addr\"); can be given more than once")
+
+	var (
+		configFile = flags.String("config", "", "read flags from `file`")
+		certFile   = flags.String("cert", "", "the TLS certificate `file` (PEM format)")
+		keyFile    = flags.String("key", "", "the TLS private key `file` (PEM format)")
+		command    = flags.String("exec", "", "run `command` for each connection")
+		dir        = flags.String("dir", "", "with -exec, run the program in `dir`")
+		uid        = flags.Int("uid", -1, "with -exec, run the program as the user `id`")
+		gid        = flags.Int("gid", -1, "with -exec, run the program as the group `id`")
+		maxConns   = flags.Int("max-conns", 0, "allow at most `n` connections at once (0 for no limit)")
+		idle       = flags.Duration("idle", 0, "close connections that have been idle this long (0 for never)")
+		useSyslog  = flags.Bool("syslog", false, "log to syslog, rather than to stderr")
+		debug      = flags.Bool("debug", false, "also log debugging messages")
+	)
+
+	if err := flags.Parse(args); nil != err {
+		return 2
+	}
+	if 0 < flags.NArg() {
+		flags.Usage()
+		return 2
+	}
+
+	if "" != *configFile {
+		if err := loadConfig(*configFile, flags); nil != err {
+			fmt.Fprintf(stderr, "telnetd: %v\n", err)
+			return 2
+		}
+	}
+
+	if err := checkFlags(tlsListen, *certFile, *keyFile, *command, forward, *uid, *gid); nil != err {
+		fmt.Fprintf(stderr, "telnetd: %v\n", err)
+		return 2
+	}
+	if 0 == len(listen) && 0 == len(tlsListen) {
+		listen = stringsFlag{":23"}
+	}
+
+
+	var logger *logger
+	if *useSyslog {
+		var err error
+		logger, err = newSyslogLogger(*debug)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnetd: %v\n", err)
+			return 1
+		}
+	} else {
+		logger = newStderrLogger(stderr, *debug)
+	}
+
+
+	var handler telnet.Handler
+	switch {
+	case "" != *command:
+		fields := strings.Fields(*command)
+		execHandler := telnet.ExecHandler{
+			Path: fields[0],
+			Args: fields[1:],
+			Dir:  *dir,
+		}
+		if 0 <= *uid {
+			execHandler.Credential = &telnet.ExecCredential{
+				UID: uint32(*uid),
+				GID: uint32(*gid),
+			}
+		}
+		handler = &execHandler
+	case 0 < len(forward):
+		handler = &telnet.ForwardHandler{
+			Backends: forwardBackends(forward),
+			Done: func(ctx telnet.Context, stats telnet.ForwardStats) {
+				logger.Infof("Forwarded to %s: %d bytes to it, %d bytes from it, over %v.", stats.Backend.Addr, stats.ToBackend, stats.FromBackend, stats.Duration.Round(time.Second))
+			},
+		}
+	default:
+		shell := telsh.NewShellHandler()
+		shell.MustRegister("help", telsh.Help(shell))
+		handler = shell
+	}
+
+
+	var tlsConfig *tls.Config
+	if 0 < len(tlsListen) {
+		certificate, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if nil != err {
+			fmt.Fprintf(stderr, "telnetd: %v\n", err)
+			return 1
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+		}
+	}
+
+
+	limits := newLimits(*maxConns, *idle, logger)
+
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+
+	for _, addr := range listen {
+		listener, err := net.Listen("tcp", addr)
+		if nil != err {
+			closeAll()
+			fmt.Fprintf(stderr, "telnetd: %v\n", err)
+			return 1
+		}
+		listeners = append(listeners, &limitListener{Listener:listener, limits:limits, plain:true})
+	}
+	for _, addr := range tlsListen {
+		listener, err := net.Listen("tcp", addr)
+		if nil != err {
+			closeAll()
+			fmt.Fprintf(stderr, "telnetd: %v\n", err)
+			return 1
+		}
+		listeners = append(listeners, tls.NewListener(&limitListener{Listener:listener, limits:limits}, tlsConfig))
+	}
+
+
+	server := telnet.Server{
+		Handler: handler,
+		Logger:  logger,
+	}
+
+	failed := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		logger.Infof("Listening at %s.", listener.Addr())
+		go func(listener net.Listener) {
+			failed <- server.Serve(listener)
+		}(listener)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case sig := <-signals:
+		logger.Infof("Stopping, on %v.", sig)
+		closeAll()
+		limits.closeAll()
+		return 0
+	case err := <-failed:
+		logger.Errorf("Stopping, on: %v", err)
+		closeAll()
+		limits.closeAll()
+		return 1
+	}
+}
+
+
+// checkFlags returns an error if the flags do not make sense together.
+func checkFlags(tlsListen []string, certFile string, keyFile string, command string, forward []string, uid int, gid int) error {
+	if 0 < len(tlsListen) && ("" == certFile || "" == keyFile) {
+		return errNoCertificate
+	}
+
+	if "" != command && 0 < len(forward) {
+		return errExecAndForward
+	}
+
+	if "" != command && 0 == len(strings.Fields(command)) {
+		return errEmptyExecCommand
+	}
+
+	if (0 <= uid) != (0 <= gid) {
+		return errUIDAndGID
+	}
+
+	return nil
+}
+
+
+// forwardBackends returns the backends for the -forward values; each of which is either an
+// address, or "name=addr".
+func forwardBackends(values []string) []telnet.ForwardBackend {
+	var backends []telnet.ForwardBackend
+
+	for _, value := range values {
+		var backend telnet.ForwardBackend
+
+		if i := strings.Index(value, "="); 0 <= i {
+			backend.Name, backend.Addr = value[:i], value[i+1:]
+		} else {
+			backend.Addr = value
+		}
+
+		backends = append(backends, backend)
+	}
+
+	return backends
+}
@@ -0,0 +1,17 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+
+import (
+	"errors"
+)
+
+
+var errSyslogNotSupported = errors.New("Syslog is not supported on this platform.")
+
+
+func newSyslogLogger(debug bool) (*logger, error) {
+	return nil, errSyslogNotSupported
+}
@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+
+import (
+	"log/syslog"
+)
+
+
+// newSyslogLogger returns a logger that writes to the local syslog, as "telnetd", with the
+// daemon facility.
+func newSyslogLogger(debug bool) (*logger, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "telnetd")
+	if nil != err {
+		return nil, err
+	}
+
+	result := logger{
+		info: func(message string) { w.Info(message) },
+		warn: func(message string) { w.Warning(message) },
+		err:  func(message string) { w.Err(message) },
+	}
+	if debug {
+		result.debug = func(message string) { w.Debug(message) }
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,27 @@
+package telnet
+
+
+// TELNET (and TELNETS) command codes.
+//
+// These are the bytes that can come after an IAC ("interpret as command").
+//
+// See RFC 854 (and RFC 885 for EOR).
+const (
+	cmdEOR  = 239 // end of record
+	cmdSE   = 240 // end of subnegotiation parameters
+	cmdNOP  = 241 // no operation
+	cmdDM   = 242 // data mark
+	cmdBRK  = 243 // break
+	cmdIP   = 244 // interrupt process
+	cmdAO   = 245 // abort output
+	cmdAYT  = 246 // are you there
+	cmdEC   = 247 // erase character
+	cmdEL   = 248 // erase line
+	cmdGA   = 249 // go ahead
+	cmdSB   = 250 // subnegotiation begin
+	cmdWILL = 251
+	cmdWONT = 252
+	cmdDO   = 253
+	cmdDONT = 254
+	cmdIAC  = 255 // interpret as command
+)
@@ -3,20 +3,48 @@ package telnet
 
 import (
 	"crypto/tls"
+	"errors"
 	"net"
+	"sync"
+	"time"
 )
 
 
+var errCloseWriteNotSupported = errors.New("CloseWrite not supported by the underlying connection.")
+
+
+// A Conn is a TELNET (or TELNETS) connection.
+//
+// Conn implements net.Conn. Read and Write deal with TELNET (and TELNETS) data, and the
+// deadlines are those of the underlying TCP (or TLS) connection.
+//
+// A Conn is what DialTo (and the other Dial funcs) return. It is also what gets passed,
+// as the Writer and the Reader, to a Handler's ServeTELNET method. So a Handler can do
+// things such as:
+//
+//	if conn, ok := w.(*telnet.Conn); ok {
+//		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+//	}
 type Conn struct {
-	conn interface {
-		Read(b []byte) (n int, err error)
-		Write(b []byte) (n int, err error)
-		Close() error
-		LocalAddr() net.Addr
-		RemoteAddr() net.Addr
-	}
+	conn       net.Conn
 	dataReader *internalDataReader
 	dataWriter *internalDataWriter
+
+	ctx        *internalContext
+	negotiator *internalNegotiator
+
+	pending []byte // data to return from Read before reading any more. (See unread.)
+
+	transcript *Transcript // nil if the session is not being logged.
+
+	addr string // the address the Conn was dialed to; empty if it was not made by a Dialer.
+
+	errMutex sync.Mutex
+	readErr  error // the first error Read returned.
+	writeErr error // the first error Write returned.
+
+	deadlineMutex sync.Mutex
+	readDeadline  time.Time // (which Read needs, for while the output is stopped by flow control.)
 }
 
 
@@ -32,29 +60,15 @@ func Dial() (*Conn, error) {
 // 'addr'.
 //
 // If a secure connection is desired, use `DialToTLS` instead.
+//
+// If the ALL_PROXY environment variable is set, then the connection is made through that
+// proxy. (See ProxyFromEnvironment.)
+//
+// To use a timeout, a context.Context, or a network other than TCP, use a `Dialer`.
 func DialTo(addr string) (*Conn, error) {
+	var dialer Dialer
 
-	const network = "tcp"
-
-	if "" == addr {
-		addr = "127.0.0.1:telnet"
-	}
-
-	conn, err := net.Dial(network, addr)
-	if nil != err {
-		return nil, err
-	}
-
-	dataReader := newDataReader(conn)
-	dataWriter := newDataWriter(conn)
-
-	clientConn := Conn{
-		conn:conn,
-		dataReader:dataReader,
-		dataWriter:dataWriter,
-	}
-
-	return &clientConn, nil
+	return dialer.Dial("tcp", addr)
 }
 
 
@@ -66,34 +80,149 @@ func DialTLS(tlsConfig *tls.Config) (*Conn, error) {
 
 // DialToTLS makes a (secure) TELNETS client connection to the the address specified by
 // 'addr'.
+//
+// To use a timeout, a context.Context, or a network other than TCP, use a `Dialer`.
 func DialToTLS(addr string, tlsConfig *tls.Config) (*Conn, error) {
-
-	const network = "tcp"
-
-	if "" == addr {
-		addr = "127.0.0.1:telnets"
+	if nil == tlsConfig {
+		tlsConfig = &tls.Config{}
 	}
 
-	conn, err := tls.Dial(network, addr, tlsConfig)
-	if nil != err {
-		return nil, err
+	dialer := Dialer{
+		TLSConfig: tlsConfig,
 	}
 
-	dataReader := newDataReader(conn)
-	dataWriter := newDataWriter(conn)
+	return dialer.Dial("tcp", addr)
+}
+
+
+// A ConnConfig configures a Conn created with NewConn or NewServerConn.
+//
+// A nil *ConnConfig (or the zero value) is fine to use, and gives the defaults.
+type ConnConfig struct {
+	Logger Logger
+
+	Lenient bool // if true, protocol errors from the other side are logged and skipped over, rather than returned by Read (as a *ProtocolError).
 
-	clientConn := Conn{
+	Transcript *Transcript // if not nil, the data sent and received is logged to this.
+
+	// Client side only. These are sent to the server, if it asks for them. (See Context.)
+	TransmitSpeed    int
+	ReceiveSpeed     int
+	XDisplayLocation string
+	Location         string
+	User             string // sent as the NEW-ENVIRON "USER" variable.
+}
+
+
+// NewConn wraps an existing connection, 'conn', as the client side of a TELNET connection.
+//
+// This makes it possible to run TELNET over connections that were not made with DialTo
+// or DialToTLS. For example, an SSH channel, a Unix socket, or one end of a net.Pipe.
+//
+// For example:
+//
+//	c, err := net.Dial("unix", "/var/run/console.sock")
+//	if nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	
+//	conn := telnet.NewConn(c, &telnet.ConnConfig{
+//		Logger: logger,
+//	})
+//	defer conn.Close()
+//
+// 'config' may be nil.
+func NewConn(conn net.Conn, config *ConnConfig) *Conn {
+	telnetConn := newClientConn(conn)
+	telnetConn.configure(config)
+
+	return telnetConn
+}
+
+
+// NewServerConn wraps an existing connection, 'conn', as the server side of a TELNET
+// connection. For example, a connection accepted by your own listener.
+//
+// The returned Conn can be passed to a Handler, along with its Context:
+//
+//	conn := telnet.NewServerConn(c, nil)
+//	defer conn.Close()
+//	
+//	handler.ServeTELNET(conn.Context(), conn, conn)
+//
+// Unlike Server, NewServerConn does not offer any options up front. (It does not send
+// anything until it is read from or written to.) It still answers whatever options the
+// client asks for.
+//
+// 'config' may be nil.
+func NewServerConn(conn net.Conn, config *ConnConfig) *Conn {
+	telnetConn := newServerConn(conn)
+	telnetConn.configure(config)
+
+	return telnetConn
+}
+
+
+// newClientConn wraps 'conn' as the client side of a TELNET (or TELNETS) connection.
+func newClientConn(conn net.Conn) *Conn {
+	return newConn(conn, false)
+}
+
+
+// newServerConn wraps 'conn' as the server side of a TELNET (or TELNETS) connection.
+func newServerConn(conn net.Conn) *Conn {
+	return newConn(conn, true)
+}
+
+
+func newConn(conn net.Conn, server bool) *Conn {
+	ctx := newContext()
+	negotiator := newNegotiator(conn, ctx, server)
+
+	dataReader := newNegotiatingDataReader(conn, negotiator)
+	dataWriter := newNegotiatingDataWriter(conn, negotiator)
+
+	telnetConn := Conn{
 		conn:conn,
 		dataReader:dataReader,
 		dataWriter:dataWriter,
+		ctx:ctx,
+		negotiator:negotiator,
+	}
+
+	return &telnetConn
+}
+
+
+func (clientConn *Conn) configure(config *ConnConfig) {
+	if nil == config {
+		return
 	}
 
-	return &clientConn, nil
+	if nil != config.Logger {
+		clientConn.ctx.InjectLogger(config.Logger)
+	}
+	clientConn.dataReader.lenient = config.Lenient
+	clientConn.transcript = config.Transcript
+
+	if !clientConn.negotiator.server {
+		clientConn.ctx.InjectTerminalSpeed(config.TransmitSpeed, config.ReceiveSpeed)
+		clientConn.ctx.InjectXDisplayLocation(config.XDisplayLocation)
+		clientConn.ctx.InjectLocation(config.Location)
+		clientConn.ctx.InjectUser(config.User)
+	}
 }
 
 
+// Context returns the Context of the connection. (Which is what gets passed to a Handler
+// or a Caller.)
+func (clientConn *Conn) Context() TerminalContext {
+	return clientConn.ctx
+}
+
 
-// Close closes the client connection.
+// Close closes the connection.
 //
 // Typical usage might look like:
 //
@@ -104,35 +233,185 @@ func DialToTLS(addr string, tlsConfig *tls.Config) (*Conn, error) {
 //	}
 //	defer telnetsClient.Close()
 func (clientConn *Conn) Close() error {
+	clientConn.negotiator.flow.release()
+
 	return clientConn.conn.Close()
 }
 
 
-// Read receives `n` bytes sent from the server to the client,
-// and "returns" into `p`.
+// Read receives `n` bytes sent from the other side (i.e., from the server to the client, or
+// from the client to the server), and "returns" into `p`.
 //
-// Note that Read can only be used for receiving TELNET (and TELNETS) data from the server.
+// Note that Read can only be used for receiving TELNET (and TELNETS) data from the other side.
 //
 // TELNET (and TELNETS) command codes cannot be received using this method, as Read deals
 // with TELNET (and TELNETS) "unescaping", and (when appropriate) filters out TELNET (and TELNETS)
 // command codes.
 //
-// Read makes Client fit the io.Reader interface.
+// If the user stopped the output (with an XOFF, under TOGGLE-FLOW-CONTROL), then Read waits
+// until it is restarted (or the read deadline passes).
+//
+// Read makes Conn fit the io.Reader interface.
 func (clientConn *Conn) Read(p []byte) (n int, err error) {
-	return clientConn.dataReader.Read(p)
+	clientConn.deadlineMutex.Lock()
+	deadline := clientConn.readDeadline
+	clientConn.deadlineMutex.Unlock()
+
+	if err := clientConn.negotiator.flow.wait(deadline); nil != err {
+		return 0, err
+	}
+
+	if 0 < len(clientConn.pending) {
+		n = copy(p, clientConn.pending)
+		clientConn.pending = clientConn.pending[n:]
+		return n, nil
+	}
+
+	n, err = clientConn.readData(p)
+	if nil != err {
+		clientConn.errMutex.Lock()
+		if nil == clientConn.readErr {
+			clientConn.readErr = err
+		}
+		clientConn.errMutex.Unlock()
+	}
+
+	return n, err
 }
 
 
-// Write sends `n` bytes from 'p' to the server.
+// Write sends `n` bytes from 'p' to the other side.
 //
-// Note that Write can only be used for sending TELNET (and TELNETS) data to the server.
+// Note that Write can only be used for sending TELNET (and TELNETS) data to the other side.
 //
 // TELNET (and TELNETS) command codes cannot be sent using this method, as Write deals with
 // TELNET (and TELNETS) "escaping", and will properly "escape" anything written with it.
 //
 // Write makes Conn fit the io.Writer interface.
 func (clientConn *Conn) Write(p []byte) (n int, err error) {
-	return clientConn.dataWriter.Write(p)
+	return clientConn.write(p, false)
+}
+
+
+// write is Write; with 'secret' being true if what is written should be masked in the
+// transcript. (See Transcript.)
+func (clientConn *Conn) write(p []byte, secret bool) (n int, err error) {
+	n, err = clientConn.dataWriter.Write(p)
+	if nil != clientConn.transcript {
+		hidden := !clientConn.negotiator.server && clientConn.negotiator.remoteEnabled(optionEcho)
+		clientConn.logTranscript(clientConn.transcript.sent(p[:n], hidden, secret))
+	}
+	if nil != err {
+		clientConn.errMutex.Lock()
+		if nil == clientConn.writeErr {
+			clientConn.writeErr = err
+		}
+		clientConn.errMutex.Unlock()
+	}
+
+	return n, err
+}
+
+
+// readData reads data from the other side; logging it to the transcript (if there is one).
+func (clientConn *Conn) readData(p []byte) (int, error) {
+	n, err := clientConn.dataReader.Read(p)
+	if nil != clientConn.transcript {
+		clientConn.logTranscript(clientConn.transcript.received(p[:n]))
+	}
+
+	return n, err
+}
+
+
+func (clientConn *Conn) logTranscript(err error) {
+	if nil != err {
+		clientConn.negotiator.logger().Errorf("Could not write the transcript: %v", err)
+	}
+}
+
+
+// unread makes 'p' be returned by Read (before anything else). It is used when data was read
+// by the library itself (for example, while logging in), but should still go to the Caller.
+func (clientConn *Conn) unread(p []byte) {
+	clientConn.pending = append(append([]byte(nil), p...), clientConn.pending...)
+}
+
+
+// errors returns the first errors Read and Write returned.
+func (clientConn *Conn) errors() (readErr error, writeErr error) {
+	clientConn.errMutex.Lock()
+	defer clientConn.errMutex.Unlock()
+
+	return clientConn.readErr, clientConn.writeErr
+}
+
+
+// RequestBinary asks the other side to use the TRANSMIT-BINARY option (RFC 856), in both
+// directions; i.e., an 8-bit data path, where a CR is just a CR. (The other side might refuse.)
+// From then on, TRANSMIT-BINARY is also accepted if the other side asks for it.
+//
+// This is only for the client side of a connection.
+func (clientConn *Conn) RequestBinary() error {
+	return clientConn.negotiator.requestBinary()
+}
+
+
+// SetFlowControl tells the client to turn its local flow control on or off, with the
+// TOGGLE-FLOW-CONTROL option (RFC 1372). When it is on, the user can stop the output with an
+// XOFF (^S), and restart it with an XON (^Q); or, if 'restartAny' is true, with any character.
+// (When it is off, XONs and XOFFs are sent to the server like any other data.) If the client
+// is not doing TOGGLE-FLOW-CONTROL yet, then it is asked to; and this is sent once it does.
+//
+// This is only for the server side of a connection. (On the client side, flow control is done
+// by the Conn itself, if it was asked to with AcceptFlowControl: the XONs and XOFFs written to it
+// stop and restart what Read returns.)
+func (clientConn *Conn) SetFlowControl(on bool, restartAny bool) error {
+	return clientConn.negotiator.setFlowControl(on, restartAny)
+}
+
+
+// AcceptFlowControl makes it so the TOGGLE-FLOW-CONTROL option (RFC 1372) is agreed to, if the
+// server asks for it. (By default, it is refused.) Then, while the server has flow control ON,
+// the XONs (^Q) and XOFFs (^S) written to the Conn restart and stop what Read returns, rather
+// than being sent. (Unless sending with TRANSMIT-BINARY.)
+//
+// This is for when what is written is typed by a user at a terminal. (StandardCaller does this
+// when os.Stdin is a terminal.)
+//
+// This is only for the client side of a connection.
+func (clientConn *Conn) AcceptFlowControl() {
+	clientConn.negotiator.acceptFlowControl()
+}
+
+
+// OfferEndOfRecord offers to do the END-OF-RECORD option (RFC 885); so that, once the other
+// side agrees, the ends of records (such as prompts) get marked by WriteEndOfRecord. (Which is
+// something a Handler that writes prompts can do before it starts.)
+func (clientConn *Conn) OfferEndOfRecord() error {
+	return clientConn.negotiator.offerLocal(optionEndOfRecord)
+}
+
+
+// RequestUser asks the client for the user name, with the NEW-ENVIRON option (RFC 1572). Once
+// the client sends it, it is what Context().User() returns.
+//
+// This is only for the server side of a connection.
+func (clientConn *Conn) RequestUser() error {
+	return clientConn.negotiator.offerRemote(optionNewEnviron)
+}
+
+
+// WriteEndOfRecord marks the end of a record, such as a prompt. (See RecordWriter.)
+func (clientConn *Conn) WriteEndOfRecord() error {
+	return clientConn.dataWriter.WriteEndOfRecord()
+}
+
+
+// EndOfRecord reports whether the data returned by the most recent call to Read ended
+// at the end of a record. (See RecordReader.)
+func (clientConn *Conn) EndOfRecord() bool {
+	return clientConn.dataReader.EndOfRecord()
 }
 
 
@@ -146,3 +425,61 @@ func (clientConn *Conn) LocalAddr() net.Addr {
 func (clientConn *Conn) RemoteAddr() net.Addr {
 	return clientConn.conn.RemoteAddr()
 }
+
+
+// dialedAddr returns the address the Conn was dialed to (before any name was resolved, and
+// not the proxy's address, if it went through one). If the Conn was not made by a Dialer,
+// then it returns the remote address.
+func (clientConn *Conn) dialedAddr() string {
+	if "" != clientConn.addr {
+		return clientConn.addr
+	}
+
+	return clientConn.RemoteAddr().String()
+}
+
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+//
+// SetDeadline (along with the rest of the methods) makes Conn fit the net.Conn interface.
+func (clientConn *Conn) SetDeadline(t time.Time) error {
+	clientConn.deadlineMutex.Lock()
+	clientConn.readDeadline = t
+	clientConn.deadlineMutex.Unlock()
+
+	return clientConn.conn.SetDeadline(t)
+}
+
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (clientConn *Conn) SetReadDeadline(t time.Time) error {
+	clientConn.deadlineMutex.Lock()
+	clientConn.readDeadline = t
+	clientConn.deadlineMutex.Unlock()
+
+	return clientConn.conn.SetReadDeadline(t)
+}
+
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (clientConn *Conn) SetWriteDeadline(t time.Time) error {
+	return clientConn.conn.SetWriteDeadline(t)
+}
+
+
+// CloseWrite shuts down the writing side of the connection. (I.e., a half-close.) After
+// which, the other side will get an io.EOF when it reads, but this side can keep reading
+// until the other side closes its side.
+//
+// This works when the underlying connection is a *net.TCPConn, a *net.UnixConn or a *tls.Conn
+// (or anything else with a CloseWrite method); otherwise it returns an error.
+func (clientConn *Conn) CloseWrite() error {
+	closeWriter, ok := clientConn.conn.(interface{ CloseWrite() error })
+	if !ok {
+		return errCloseWriteNotSupported
+	}
+
+	clientConn.negotiator.closeWrite()
+
+	return closeWriter.CloseWrite()
+}
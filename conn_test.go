@@ -0,0 +1,245 @@
+package telnet
+
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"testing"
+)
+
+
+var _ net.Conn = &Conn{}
+
+
+type connTestHandler func(Context, Writer, Reader)
+
+func (fn connTestHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	fn(ctx, w, r)
+}
+
+
+func TestConnServerSideDeadline(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	results := make(chan error, 1)
+
+	handler := connTestHandler(func(ctx Context, w Writer, r Reader) {
+		conn, ok := r.(net.Conn)
+		if !ok {
+			results <- nil
+			return
+		}
+
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+		_, err := conn.Read(make([]byte, 16))
+		results <- err
+	})
+
+	go Serve(listener, handler)
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	select {
+	case err := <-results:
+		if nil == err {
+			t.Fatalf("Expected the Reader passed to the handler to be a net.Conn that timed out.")
+		}
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("Expected a timeout error, but actually got: (%T) %v", err, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The handler's read deadline did not work.")
+	}
+}
+
+
+func TestConnCloseWrite(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := connTestHandler(func(ctx Context, w Writer, r Reader) {
+		w.Write([]byte("ready"))
+
+		received, _ := ioutil.ReadAll(r)
+		w.Write([]byte("received: "))
+		w.Write(received)
+	})
+
+	go Serve(listener, handler)
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Reading first means any option negotiation from the server has been answered,
+	// before the writing side gets shut down.
+	ready := make([]byte, len("ready"))
+	if _, err := io.ReadFull(conn, ready); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, err := conn.Write([]byte("apple")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := conn.CloseWrite(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p, err := ioutil.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "received: apple", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+// TestConnCloseWriteBeforeNegotiation checks that, when the writing side is shut down before the
+// server's option negotiation arrives, the replies that can no longer be sent do not make Read fail.
+func TestConnCloseWriteBeforeNegotiation(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := connTestHandler(func(ctx Context, w Writer, r Reader) {
+		received, _ := ioutil.ReadAll(r)
+		w.Write([]byte("received: "))
+		w.Write(received)
+	})
+
+	go Serve(listener, handler)
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("apple")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := conn.CloseWrite(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p, err := ioutil.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "received: apple", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestConnCloseWriteNotSupported(t *testing.T) {
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	conn := newClientConn(c1)
+
+	if expected, actual := errCloseWriteNotSupported, conn.CloseWrite(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+
+
+func TestNewConnOverPipe(t *testing.T) {
+
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+
+	client := NewConn(clientSide, &ConnConfig{
+		Location: "Building 4, Room 112",
+	})
+	server := NewServerConn(serverSide, &ConnConfig{
+		Lenient: true,
+	})
+
+	if expected, actual := "Building 4, Room 112", client.Context().Location(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if !server.dataReader.lenient {
+		t.Errorf("Expected the server side to be lenient.")
+	}
+
+	go func() {
+		server.Write([]byte("hello \xff"))
+	}()
+
+	p := make([]byte, len("hello \xff"))
+	if _, err := io.ReadFull(client, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello \xff", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestServerOffersNothingByDefault(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, EchoHandler)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	p, err := ioutil.ReadAll(io.LimitReader(conn, int64(len("hello"))))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "hello", string(p); expected != actual {
+		t.Errorf("Expected %q (without any option negotiation), but actually got %q.", expected, actual)
+	}
+}
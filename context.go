@@ -1,6 +1,15 @@
 package telnet
 
 
+import (
+	"sync"
+)
+
+
+// A Context holds the per-connection state of a TELNET (or TELNETS) connection.
+//
+// The Contexts this package creates (i.e., the ones passed to a Handler or a Caller) are also
+// TerminalContexts.
 type Context interface {
 	Logger() Logger
 
@@ -8,12 +17,94 @@ type Context interface {
 }
 
 
+// A TerminalContext is a Context that also holds what is known about the terminal at the other
+// end of the connection.
+//
+// On the server side, values such as the terminal speed, X display location, location and user
+// are filled in as the client sends them, by way of the TERMINAL-SPEED (RFC 1079),
+// X-DISPLAY-LOCATION (RFC 1096), SEND-LOCATION (RFC 779) and NEW-ENVIRON (RFC 1572) options.
+//
+// On the client side, these values (as well as the terminal type and window size, by way of
+// the TERMINAL-TYPE (RFC 1091) and NAWS (RFC 1073) options) are what gets sent to the server
+// when it asks for them. (If a value is not set, the client refuses the corresponding option.) A Caller can set
+// them, with the Inject methods, before it starts reading.
+//
+// For example:
+//
+//	func (handler myHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader) {
+//		if terminal, ok := ctx.(telnet.TerminalContext); ok {
+//			width, height := terminal.WindowSize()
+//
+//			//...
+//		}
+//
+//		//...
+//	}
+type TerminalContext interface {
+	Context
+
+	// TerminalType returns the terminal type, such as "xterm-256color". (I.e., what $TERM would be.)
+	TerminalType() string
+
+	// WindowSize returns the width and height of the terminal window (in characters).
+	// Both are zero if not known.
+	WindowSize() (width int, height int)
+
+	// TerminalSpeed returns the transmit and receive speeds (in bits per second).
+	// Both are zero if not known.
+	TerminalSpeed() (transmit int, receive int)
+
+	// XDisplayLocation returns the X display location, such as "example.net:0.0".
+	XDisplayLocation() string
+
+	// Location returns the (physical) location of the terminal, such as "Building 4, Room 112".
+	Location() string
+
+	// User returns the user name, as sent with the NEW-ENVIRON "USER" variable.
+	User() string
+
+	InjectTerminalType(string) TerminalContext
+	InjectWindowSize(width int, height int) TerminalContext
+	InjectTerminalSpeed(transmit int, receive int) TerminalContext
+	InjectXDisplayLocation(string) TerminalContext
+	InjectLocation(string) TerminalContext
+	InjectUser(string) TerminalContext
+}
+
+
 type internalContext struct {
+	mutex sync.RWMutex
+
 	logger Logger
+
+	terminalType     string
+	width            int
+	height           int
+	transmitSpeed    int
+	receiveSpeed     int
+	xDisplayLocation string
+	location         string
+	user             string
 }
 
 
 func NewContext() Context {
+	return newContext()
+}
+
+
+// terminalContext returns 'ctx' as a TerminalContext; or, if it is not one, an empty
+// TerminalContext (in which nothing is known about the terminal).
+func terminalContext(ctx Context) TerminalContext {
+	if terminal, ok := ctx.(TerminalContext); ok {
+		return terminal
+	}
+
+	return newContext()
+}
+
+
+func newContext() *internalContext {
 	ctx := internalContext{}
 
 	return &ctx
@@ -21,11 +112,109 @@ func NewContext() Context {
 
 
 func (ctx *internalContext) Logger() Logger {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
 	return ctx.logger
 }
 
+func (ctx *internalContext) TerminalType() string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.terminalType
+}
+
+func (ctx *internalContext) WindowSize() (width int, height int) {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.width, ctx.height
+}
+
+func (ctx *internalContext) TerminalSpeed() (transmit int, receive int) {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.transmitSpeed, ctx.receiveSpeed
+}
+
+func (ctx *internalContext) XDisplayLocation() string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.xDisplayLocation
+}
+
+func (ctx *internalContext) Location() string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.location
+}
+
+func (ctx *internalContext) User() string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.user
+}
+
+
 func (ctx *internalContext) InjectLogger(logger Logger) Context {
+	ctx.mutex.Lock()
 	ctx.logger = logger
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectTerminalType(terminalType string) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.terminalType = terminalType
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectWindowSize(width int, height int) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.width = width
+	ctx.height = height
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectTerminalSpeed(transmit int, receive int) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.transmitSpeed = transmit
+	ctx.receiveSpeed  = receive
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectXDisplayLocation(xDisplayLocation string) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.xDisplayLocation = xDisplayLocation
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectLocation(location string) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.location = location
+	ctx.mutex.Unlock()
+
+	return ctx
+}
+
+func (ctx *internalContext) InjectUser(user string) TerminalContext {
+	ctx.mutex.Lock()
+	ctx.user = user
+	ctx.mutex.Unlock()
 
 	return ctx
 }
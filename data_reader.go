@@ -3,14 +3,16 @@ package telnet
 
 import (
 	"bufio"
-	"errors"
+	"bytes"
 	"io"
 )
 
 
-var (
-	errCorrupted = errors.New("Corrupted")
-)
+// How many of the most recently received bytes are remembered (for a ProtocolError).
+const dataReaderRecentSize = 8
+
+// The longest subnegotiation (i.e., what is between the IAC SB and the IAC SE) that is read.
+const maxSubnegotiationSize = 4096
 
 
 // An internalDataReader deals with "un-escaping" according to the TELNET protocol.
@@ -55,9 +57,32 @@ var (
 // ... to this:
 //
 //	[]byte{1, 55, 2, 155, 3, 255, 4, 40, 255, 30, 20}
+//
+// If it has an internalNegotiator, then the TELNET (and TELNETS) option negotiation commands
+// (and subnegotiations) it filters out are handed to it.
+//
+// If it receives something that does not follow the TELNET protocol, then Read returns
+// a *ProtocolError. Unless it is lenient, in which case it logs the problem, skips over
+// it, and keeps going.
 type internalDataReader struct {
 	wrapped  io.Reader
 	buffered  *bufio.Reader
+
+	negotiator *internalNegotiator
+
+	subnegotiation       bytes.Buffer
+	inSubnegotiation     bool  // whether reading a subnegotiation was cut short (such as by a read timeout).
+	skipSubnegotiation   bool  // whether the subnegotiation being read is too long, and is being skipped over.
+	subnegotiationOffset int64 // the offset of the IAC SB.
+
+	endOfRecord bool
+	unmarked    bool // whether the data last returned has not been marked as the end of a record (yet).
+
+	lenient bool
+
+	offset    int64 // How many bytes have been consumed from the wrapped io.Reader.
+	recent    [dataReaderRecentSize]byte
+	recentLen int
 }
 
 
@@ -74,100 +99,367 @@ func newDataReader(r io.Reader) *internalDataReader {
 }
 
 
+// newNegotiatingDataReader creates a new DataReader reading from 'r', that hands
+// option negotiation commands (and subnegotiations) to 'negotiator'.
+func newNegotiatingDataReader(r io.Reader, negotiator *internalNegotiator) *internalDataReader {
+	reader := newDataReader(r)
+	reader.negotiator = negotiator
+
+	return reader
+}
+
+
 // Read reads the TELNET escaped data from the  wrapped io.Reader, and "un-escapes" it into 'data'.
+//
+// Read returns as soon as it has some data. I.e., it does not wait for 'data' to fill up.
+// (It only blocks when it does not have any data to return yet.)
+//
+// TELNET (and TELNETS) commands are dealt with along the way. (Although, once Read has
+// some data, it will only deal with the commands it can without blocking.)
+//
+// Read also stops at the end of a record. (See EndOfRecord.) If the IAC EOR (or IAC GA) only
+// arrives after the data before it was already returned, then Read returns 0 bytes (and a nil
+// error), with EndOfRecord true; i.e., a zero-length record, so that the boundary is not lost.
 func (r *internalDataReader) Read(data []byte) (n int, err error) {
 
-	const IAC = 255
+	r.endOfRecord = false
+	defer func() {
+		if 0 < n {
+			r.unmarked = !r.endOfRecord
+		}
+	}()
+
+	for n < len(data) {
+
+		// Once we have some data, only keep going while we can do so without blocking.
+		if 0 < n && r.buffered.Buffered() <= 0 {
+			break
+		}
+
+		// If reading a subnegotiation was cut short, then carry on with it.
+		if r.inSubnegotiation {
+			if 0 < n {
+				break
+			}
+
+			err = r.readSubnegotiation()
+			if nil != err {
+				return n, err
+			}
+			continue
+		}
+
+		// This only blocks if nothing is buffered.
+		_, err = r.buffered.Peek(1)
+		if nil != err {
+			return n, err
+		}
+
+		buffered, _ := r.buffered.Peek(r.buffered.Buffered())
+
+		if cmdIAC != buffered[0] {
+			run := buffered
+			if i := bytes.IndexByte(run, cmdIAC); 0 <= i {
+				run = run[:i]
+			}
+			if room := len(data) - n; room < len(run) {
+				run = run[:room]
+			}
 
-	const SB = 250
-	const SE = 240
+			n += copy(data[n:], run)
+			r.discard(len(run))
+			continue
+		}
 
-	const WILL = 251
-	const WONT = 252
-	const DO   = 253
-	const DONT = 254
 
-	p := data
+		// If we get here, we are at an IAC.
 
-	for len(p) > 0 {
-		var b byte
+		if 0 < n && !commandBuffered(buffered) {
+			break
+		}
 
-		b, err = r.buffered.ReadByte()
+		var peeked []byte
+		peeked, err = r.buffered.Peek(2)
 		if nil != err {
 			return n, err
 		}
 
-		if IAC == b {
-			var peeked []byte
+		switch peeked[1] {
+		case cmdIAC:
+			n += copy(data[n:], peeked[1:2])
+			r.discard(2)
+		case cmdEOR, cmdGA:
+			r.discard(2)
+
+			// An IAC EOR (or IAC GA) marks the end of a record (such as a prompt).
+			// So we stop here, so that the caller can find out about it.
+			if 0 < n {
+				r.endOfRecord = true
+				return n, nil
+			}
 
-			peeked, err = r.buffered.Peek(1)
+			// It marks the end of what the previous Read returned.
+			if r.unmarked {
+				r.unmarked = false
+				r.endOfRecord = true
+				return 0, nil
+			}
+		default:
+			err = r.command()
 			if nil != err {
 				return n, err
 			}
+		}
+	}
 
-			switch peeked[0] {
-			case WILL, WONT, DO, DONT:
-				_, err = r.buffered.Discard(2)
-				if nil != err {
-					return n, err
-				}
-			case IAC:
-				p[0] = IAC
-				n++
-				p = p[1:]
-
-				_, err = r.buffered.Discard(1)
-				if nil != err {
-					return n, err
-				}
-			case SB:
-				for {
-					var b2 byte
-					b2, err = r.buffered.ReadByte()
-					if nil != err {
-						return n, err
-					}
-
-					if IAC == b2 {
-						peeked, err = r.buffered.Peek(1)
-						if nil != err {
-							return n, err
-						}
-
-						if IAC == peeked[0] {
-							_, err = r.buffered.Discard(1)
-							if nil != err {
-								return n, err
-							}
-						}
-
-						if SE == peeked[0] {
-							_, err = r.buffered.Discard(1)
-							if nil != err {
-								return n, err
-							}
-							break
-						}
-					}
-				}
-			case SE:
-				_, err = r.buffered.Discard(1)
-				if nil != err {
-					return n, err
-				}
-			default:
-				// If we get in here, this is not following the TELNET protocol.
-//@TODO: Make a better error.
-				err = errCorrupted
-				return n, err
+	// If the record ends right where 'data' got filled up, then (if we can tell without
+	// blocking) consume the IAC EOR (or IAC GA) now, so the boundary is not lost.
+	if 2 <= r.buffered.Buffered() {
+		peeked, _ := r.buffered.Peek(2)
+		if cmdIAC == peeked[0] && (cmdEOR == peeked[1] || cmdGA == peeked[1]) {
+			r.discard(2)
+			r.endOfRecord = true
+		}
+	}
+
+	return n, nil
+}
+
+
+// command deals with the TELNET (or TELNETS) command at the front of the buffer.
+// (I.e., the buffer starts with an IAC that is not followed by another IAC.)
+func (r *internalDataReader) command() error {
+
+	peeked, err := r.buffered.Peek(2)
+	if nil != err {
+		return err
+	}
+
+	switch peeked[1] {
+	case cmdWILL, cmdWONT, cmdDO, cmdDONT:
+		peeked, err = r.buffered.Peek(3)
+		if nil != err {
+			return err
+		}
+
+		command, option := peeked[1], peeked[2]
+		r.discard(3)
+
+		if nil != r.negotiator {
+			return r.negotiator.negotiate(command, option)
+		}
+	case cmdSB:
+		r.subnegotiationOffset = r.offset
+		r.discard(2)
+
+		r.subnegotiation.Reset()
+		r.inSubnegotiation = true
+		r.skipSubnegotiation = false
+
+		return r.readSubnegotiation()
+	case cmdSE, cmdNOP, cmdDM:
+		r.discard(2)
+	case cmdBRK, cmdIP, cmdAO, cmdAYT, cmdEC, cmdEL:
+		command := peeked[1]
+		r.discard(2)
+
+		if nil != r.negotiator {
+			r.negotiator.receivedCommand(command)
+		}
+	default:
+		// If we get in here, this is not following the TELNET protocol.
+		return r.protocolError(peeked[1])
+	}
+
+	return nil
+}
+
+
+// readSubnegotiation reads the rest of a subnegotiation, up to (and including) the IAC SE, and
+// then deals with it.
+//
+// If reading fails part way through (such as because of a read timeout), then what was read so
+// far is kept, and the next call carries on from there.
+func (r *internalDataReader) readSubnegotiation() error {
+	for {
+		peeked, err := r.buffered.Peek(1)
+		if nil != err {
+			return err
+		}
+
+		if cmdIAC == peeked[0] {
+			peeked, err = r.buffered.Peek(2)
+			if nil != err {
+				return err
+			}
+
+			if cmdSE == peeked[1] {
+				r.discard(2)
+				break
+			}
+		}
+
+		if !r.skipSubnegotiation && maxSubnegotiationSize <= r.subnegotiation.Len() {
+			if err := r.subnegotiationTooLong(); nil != err {
+				return err
 			}
+		}
+
+		b := peeked[0]
+		if cmdIAC == b && cmdIAC == peeked[1] {
+			r.discard(2)
 		} else {
+			r.discard(1)
+		}
 
-			p[0] = b
-			n++
-			p = p[1:]
+		if !r.skipSubnegotiation {
+			r.subnegotiation.WriteByte(b)
 		}
 	}
 
-	return n, nil
+	r.inSubnegotiation = false
+
+	if r.skipSubnegotiation || nil == r.negotiator || 0 == r.subnegotiation.Len() {
+		return nil
+	}
+
+	sb := r.subnegotiation.Bytes()
+
+	return r.negotiator.subnegotiate(sb[0], sb[1:])
+}
+
+
+// subnegotiationTooLong deals with a subnegotiation that is longer than maxSubnegotiationSize.
+//
+// If the reader is lenient, then the problem is logged, the rest of the subnegotiation is
+// skipped over, and nil is returned. Else a *ProtocolError is returned. (And keeps being
+// returned, since nothing more of the subnegotiation is consumed.)
+func (r *internalDataReader) subnegotiationTooLong() error {
+
+	surrounding := []byte{cmdIAC, cmdSB}
+	if p := r.subnegotiation.Bytes(); dataReaderRecentSize-2 < len(p) {
+		surrounding = append(surrounding, p[:dataReaderRecentSize-2]...)
+	} else {
+		surrounding = append(surrounding, p...)
+	}
+
+	err := &ProtocolError{
+		Command:cmdSB,
+		Offset:r.subnegotiationOffset,
+		Surrounding:surrounding,
+	}
+
+	if !r.lenient {
+		return err
+	}
+
+	r.logger().Warnf("Skipping: %v", err)
+	r.skipSubnegotiation = true
+	r.subnegotiation.Reset()
+
+	return nil
+}
+
+
+// protocolError deals with an IAC (at the front of the buffer) followed by 'command', which
+// is not a TELNET (or TELNETS) command.
+//
+// If the reader is lenient, then the problem is logged, the IAC and the bad byte are skipped
+// over, and nil is returned. Else a *ProtocolError is returned.
+func (r *internalDataReader) protocolError(command byte) error {
+
+	surrounding := make([]byte, r.recentLen, r.recentLen+dataReaderRecentSize)
+	copy(surrounding, r.recent[:r.recentLen])
+
+	after := r.buffered.Buffered()
+	if dataReaderRecentSize < after {
+		after = dataReaderRecentSize
+	}
+	peeked, _ := r.buffered.Peek(after)
+	surrounding = append(surrounding, peeked...)
+
+	err := &ProtocolError{
+		Command:command,
+		Offset:r.offset,
+		Surrounding:surrounding,
+	}
+
+	if !r.lenient {
+		return err
+	}
+
+	r.logger().Warnf("Skipping: %v", err)
+	r.discard(2)
+
+	return nil
+}
+
+
+func (r *internalDataReader) logger() Logger {
+	if nil == r.negotiator {
+		return internalDiscardLogger{}
+	}
+
+	return r.negotiator.logger()
+}
+
+
+// discard consumes the next 'n' bytes, which must already be buffered, remembering the
+// most recent ones along the way.
+func (r *internalDataReader) discard(n int) {
+	p, _ := r.buffered.Peek(n)
+
+	if dataReaderRecentSize <= len(p) {
+		copy(r.recent[:], p[len(p)-dataReaderRecentSize:])
+		r.recentLen = dataReaderRecentSize
+	} else {
+		keep := dataReaderRecentSize - len(p)
+		if r.recentLen < keep {
+			keep = r.recentLen
+		}
+		copy(r.recent[:keep], r.recent[r.recentLen-keep:r.recentLen])
+		copy(r.recent[keep:], p)
+		r.recentLen = keep + len(p)
+	}
+
+	r.buffered.Discard(n) // Cannot fail, since these bytes are already buffered.
+	r.offset += int64(n)
+}
+
+
+// commandBuffered reports whether the whole TELNET (or TELNETS) command at the front of
+// 'p' is in 'p'. (Where 'p' starts with an IAC.)
+func commandBuffered(p []byte) bool {
+	if len(p) < 2 {
+		return false
+	}
+
+	switch p[1] {
+	case cmdWILL, cmdWONT, cmdDO, cmdDONT:
+		return 3 <= len(p)
+	case cmdSB:
+		for i := 2; i < len(p); i++ {
+			if cmdIAC != p[i] {
+				continue
+			}
+			if len(p) <= i+1 {
+				return false
+			}
+			if cmdSE == p[i+1] {
+				return true
+			}
+			i++
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+
+// EndOfRecord reports whether the data returned by the most recent call to Read
+// ended at the end of a record. I.e., was followed by an IAC EOR or an IAC GA. (If that
+// Read returned 0 bytes, then it is the data returned before it that ended the record.)
+func (r *internalDataReader) EndOfRecord() bool {
+	return r.endOfRecord
 }
@@ -3,7 +3,12 @@ package telnet
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"os"
+	"time"
 
 	"testing"
 )
@@ -351,3 +356,276 @@ func TestDataReader(t *testing.T) {
 		}
 	}
 }
+
+
+func TestDataReaderEndOfRecord(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Expected []string
+	}{
+		{
+			Bytes:    []byte("login: \xff\xef"), // "login: " IAC EOR
+			Expected: []string{"login: |"},
+		},
+		{
+			Bytes:    []byte("login: \xff\xf9"), // "login: " IAC GA
+			Expected: []string{"login: |"},
+		},
+		{
+			Bytes:    []byte("Welcome!\r\n$ \xff\xefls\r\n$ \xff\xef"), // "Welcome!\r\n$ " IAC EOR "ls\r\n$ " IAC EOR
+			Expected: []string{"Welcome!\r\n$ |", "ls\r\n$ |"},
+		},
+		{
+			Bytes:    []byte("\xff\xef\xff\xf9$ \xff\xef"), // IAC EOR IAC GA "$ " IAC EOR
+			Expected: []string{"$ |"},
+		},
+		{
+			Bytes:    []byte("no prompt here"),
+			Expected: []string{"no prompt here"},
+		},
+		{
+			Bytes:    []byte("a\xff\xf1b\xff\xf3c"), // 'a' IAC NOP 'b' IAC BRK 'c'
+			Expected: []string{"abc"},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		reader := newDataReader(bytes.NewReader(test.Bytes))
+
+		var actual []string
+
+		buffer := make([]byte, 64)
+		for {
+			n, err := reader.Read(buffer)
+			if 0 < n {
+				s := string(buffer[:n])
+				if reader.EndOfRecord() {
+					s += "|"
+				}
+				actual = append(actual, s)
+			}
+			if io.EOF == err {
+				break
+			}
+			if nil != err {
+				t.Errorf("For test #%d, did not expected an error, but actually got one: (%T) %v", testNumber, err, err)
+				break
+			}
+		}
+
+		if expected, actual := fmt.Sprintf("%q", test.Expected), fmt.Sprintf("%q", actual); expected != actual {
+			t.Errorf("For test #%d, expected %s, but actually got %s.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestDataReaderReturnsWithoutFillingBuffer(t *testing.T) {
+
+	tests := []struct{
+		Writes   [][]byte
+		Expected []string
+	}{
+		{
+			Writes:   [][]byte{[]byte("hello")},
+			Expected: []string{"hello"},
+		},
+		{
+			Writes:   [][]byte{[]byte("hel"), []byte("lo")},
+			Expected: []string{"hel", "lo"},
+		},
+		{
+			Writes:   [][]byte{[]byte("a\xff\xffb")},
+			Expected: []string{"a\xffb"},
+		},
+		{
+			Writes:   [][]byte{[]byte("a\xff"), []byte("\xffb")}, // IAC IAC split across writes.
+			Expected: []string{"a", "\xffb"},
+		},
+		{
+			Writes:   [][]byte{[]byte("a\xff\xfb"), []byte("\x18b")}, // IAC WILL TERMINAL-TYPE split across writes.
+			Expected: []string{"a", "b"},
+		},
+		{
+			Writes:   [][]byte{[]byte("a\xff\xfa\x18\x01"), []byte("\xff\xf0b")}, // IAC SB TERMINAL-TYPE SEND IAC SE split across writes.
+			Expected: []string{"a", "b"},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		pipeReader, pipeWriter := io.Pipe()
+
+		reader := newDataReader(pipeReader)
+
+		buffer := make([]byte, 1024)
+
+		for i, write := range test.Writes {
+
+			go pipeWriter.Write(write)
+
+			type result struct {
+				n   int
+				err error
+			}
+			results := make(chan result, 1)
+			go func() {
+				n, err := reader.Read(buffer)
+				results <- result{n, err}
+			}()
+
+			select {
+			case r := <-results:
+				if nil != r.err {
+					t.Errorf("For test #%d and read #%d, did not expected an error, but actually got one: (%T) %v", testNumber, i, r.err, r.err)
+					break
+				}
+				if expected, actual := test.Expected[i], string(buffer[:r.n]); expected != actual {
+					t.Errorf("For test #%d and read #%d, expected %q, but actually got %q.", testNumber, i, expected, actual)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("For test #%d and read #%d, Read blocked.", testNumber, i)
+				pipeWriter.Close()
+				<-results
+			}
+		}
+
+		pipeWriter.Close()
+	}
+}
+
+
+// dataReaderTestTimeoutReader returns each of its chunks from a Read; with a read timeout
+// (i.e., os.ErrDeadlineExceeded) in between each.
+type dataReaderTestTimeoutReader struct {
+	chunks  [][]byte
+	timeout bool
+}
+
+func (r *dataReaderTestTimeoutReader) Read(p []byte) (int, error) {
+	if len(r.chunks) <= 0 {
+		return 0, io.EOF
+	}
+
+	if r.timeout {
+		r.timeout = false
+		return 0, os.ErrDeadlineExceeded
+	}
+	r.timeout = true
+
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+
+	return n, nil
+}
+
+
+func TestDataReaderSubnegotiationAcrossTimeout(t *testing.T) {
+
+	ctx := newContext()
+
+	var buffer bytes.Buffer
+	negotiator := newNegotiator(&buffer, ctx, true)
+	negotiator.remote[optionTerminalType] = true
+
+	reader := newNegotiatingDataReader(&dataReaderTestTimeoutReader{
+		chunks: [][]byte{
+			[]byte("a\xff\xfa\x18\x00xt"), // 'a' IAC SB TERMINAL-TYPE IS "xt"
+			[]byte("erm\xff\xf0b"),        // "erm" IAC SE 'b'
+		},
+	}, negotiator)
+
+	p := make([]byte, 64)
+
+	n, err := reader.Read(p)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "a", string(p[:n]); expected != actual {
+		t.Fatalf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	n, err = reader.Read(p)
+	if os.ErrDeadlineExceeded != err {
+		t.Fatalf("Expected a timeout, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := 0, n; expected != actual {
+		t.Fatalf("Expected %d, but actually got %d.", expected, actual)
+	}
+
+	n, err = reader.Read(p)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "b", string(p[:n]); expected != actual {
+		t.Fatalf("Expected %q (and not the rest of the subnegotiation), but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := "xterm", ctx.TerminalType(); expected != actual {
+		t.Errorf("Expected the terminal type %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestDataReaderEndOfRecordSplit(t *testing.T) {
+
+	// The IAC EOR arrives in a later read than the data before it.
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		server.Write([]byte("$ "))
+		server.Write([]byte{255,239}) // IAC EOR
+		server.Write([]byte("ls"))
+	}()
+
+	readers := []io.Reader{
+		client,
+		&dataReaderTestTimeoutReader{
+			chunks: [][]byte{
+				[]byte("$ "),
+				[]byte{255,239}, // IAC EOR
+				[]byte("ls"),
+			},
+		},
+	}
+
+
+	for testNumber, wrapped := range readers {
+
+		reader := newDataReader(wrapped)
+
+		var actual []string
+
+		buffer := make([]byte, 64)
+		for {
+			n, err := reader.Read(buffer)
+			if 0 < n || reader.EndOfRecord() {
+				s := string(buffer[:n])
+				if reader.EndOfRecord() {
+					s += "|"
+				}
+				actual = append(actual, s)
+			}
+			if io.EOF == err {
+				break
+			}
+			if nil != err && !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("For test #%d, did not expected an error, but actually got one: (%T) %v", testNumber, err, err)
+				break
+			}
+		}
+
+		if expected, actual := fmt.Sprintf("%q", []string{"$ ", "|", "ls"}), fmt.Sprintf("%q", actual); expected != actual {
+			t.Errorf("For test #%d, expected %s, but actually got %s.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
@@ -5,15 +5,22 @@ import (
 	"github.com/reiver/go-oi"
 
 	"bytes"
-	"errors"
 	"io"
+	"sync"
 )
 
 
-var iaciac []byte = []byte{255, 255}
+const (
+	dataWriterBufferSize    = 1024 // The size of the (pooled) buffers used to gather up short runs of data and escaped IACs.
+	dataWriterDirectRunSize =  256 // Runs of data (without an IAC) at least this long are written directly.
+)
+
 
-var errOverflow = errors.New("Overflow")
-var errPartialIACIACWrite = errors.New("Partial IAC IAC write.")
+var dataWriterBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([dataWriterBufferSize]byte)
+	},
+}
 
 
 // An internalDataWriter deals with "escaping" according to the TELNET (and TELNETS) protocol.
@@ -45,8 +52,14 @@ var errPartialIACIACWrite = errors.New("Partial IAC IAC write.")
 // (Notice that each "255" in the original byte array became 2 "255"s in a row.)
 //
 // internalDataWriter takes care of all this for you, so you do not have to do it.
+//
+// If it has an internalNegotiator, then writing also honors TOGGLE-FLOW-CONTROL (RFC 1372).
+// I.e., when flow control is ON, the XONs and XOFFs written restart and stop the output (rather
+// than being sent). (See internalFlowControl.)
 type internalDataWriter struct {
 	wrapped io.Writer
+
+	negotiator *internalNegotiator
 }
 
 
@@ -77,66 +90,180 @@ func newDataWriter(w io.Writer) *internalDataWriter {
 }
 
 
+// newNegotiatingDataWriter creates a new internalDataWriter writing to 'w', that
+// honors the options negotiated by 'negotiator'.
+func newNegotiatingDataWriter(w io.Writer, negotiator *internalNegotiator) *internalDataWriter {
+	writer := newDataWriter(w)
+	writer.negotiator = negotiator
+
+	return writer
+}
+
+
 // Write writes the TELNET (and TELNETS) escaped data for of the data in 'data' to the wrapped io.Writer.
+//
+// Runs of data that do not contain an IAC, and are long enough, are written directly from
+// 'data'. Short runs, and the escaped IACs, are gathered up in a (pooled) buffer first, so
+// that data with lots of IACs in it does not turn into lots of little writes.
+//
+// The returned 'n' is in terms of the bytes in 'data'. So, if the wrapped io.Writer fails
+// part way through, 'n' is how many bytes of 'data' were completely written (escaping and all).
 func (w *internalDataWriter) Write(data []byte) (n int, err error) {
-	var n64 int64
 
-	n64, err = w.write64(data)
-	n = int(n64)
-	if int64(n) != n64 {
-		panic(errOverflow)
+	if nil == w.negotiator || !w.negotiator.flow.active() {
+		return w.write(data)
 	}
 
-	return n, err
+	// TOGGLE-FLOW-CONTROL is active; so the XONs and XOFFs are acted on, rather than sent.
+	for n < len(data) {
+		if w.negotiator.flow.observe(data[n]) {
+			n++
+			continue
+		}
+
+		end := n + 1
+		for end < len(data) && asciiXON != data[end] && asciiXOFF != data[end] {
+			end++
+		}
+
+		var written int
+		written, err = w.write(data[n:end])
+		n += written
+		if nil != err {
+			return n, err
+		}
+	}
+
+	return n, nil
 }
 
 
-func (w *internalDataWriter) write64(data []byte) (n int64, err error) {
+// write escapes and writes 'data'. (Which is what Write does, without flow control.)
+func (w *internalDataWriter) write(data []byte) (n int, err error) {
 
 	if len(data) <= 0 {
 		return 0, nil
 	}
 
-	const IAC = 255
+	bufferPtr := dataWriterBufferPool.Get().(*[dataWriterBufferSize]byte)
+	defer dataWriterBufferPool.Put(bufferPtr)
 
-	var buffer bytes.Buffer
-	for _, datum := range data {
+	pending := bufferPtr[:0]
 
-		if IAC == datum {
+	var written int64 // How many (escaped) bytes were written to the wrapped io.Writer.
 
-			if buffer.Len() > 0 {
-				var numWritten int64
+	p := data
+	for 0 < len(p) {
 
-				numWritten, err = oi.LongWrite(w.wrapped, buffer.Bytes())
-				n += numWritten
+		if cmdIAC == p[0] {
+			if cap(pending) < len(pending)+2 {
+				written, err = w.flush(written, pending)
 				if nil != err {
-					return n, err
+					return escapedPrefixLen(data, written), err
 				}
-				buffer.Reset()
+				pending = pending[:0]
 			}
 
+			pending = append(pending, cmdIAC, cmdIAC)
+			p = p[1:]
+			continue
+		}
+
+		run := p
+		if i := bytes.IndexByte(p, cmdIAC); 0 <= i {
+			run = p[:i]
+		}
 
-			var numWritten int64
-			//@TODO: Should we worry about "iaciac" potentially being modified by the .Write()?
-			numWritten, err = oi.LongWrite(w.wrapped, iaciac)
-			if int64(len(iaciac)) != numWritten {
-				//@TODO: Do we really want to panic() here?
-				panic(errPartialIACIACWrite)
+		switch {
+		case dataWriterDirectRunSize <= len(run):
+			if 0 < len(pending) {
+				written, err = w.flush(written, pending)
+				if nil != err {
+					return escapedPrefixLen(data, written), err
+				}
+				pending = pending[:0]
 			}
-			n += 1
+
+			written, err = w.flush(written, run)
 			if nil != err {
-				return n, err
+				return escapedPrefixLen(data, written), err
+			}
+		default:
+			if cap(pending) < len(pending)+len(run) {
+				written, err = w.flush(written, pending)
+				if nil != err {
+					return escapedPrefixLen(data, written), err
+				}
+				pending = pending[:0]
 			}
+
+			pending = append(pending, run...)
+		}
+
+		p = p[len(run):]
+	}
+
+	if 0 < len(pending) {
+		written, err = w.flush(written, pending)
+		if nil != err {
+			return escapedPrefixLen(data, written), err
+		}
+	}
+
+	return len(data), nil
+}
+
+
+// flush writes 'p' to the wrapped io.Writer, and returns the new total of (escaped) bytes written.
+func (w *internalDataWriter) flush(written int64, p []byte) (int64, error) {
+	n, err := oi.LongWrite(w.wrapped, p)
+	return written + n, err
+}
+
+
+// escapedPrefixLen returns how many bytes of 'data' were completely written, given that
+// 'written' bytes of (escaped) data were written.
+//
+// (An IAC only counts as written if both bytes of its escaped form were written.)
+func escapedPrefixLen(data []byte, written int64) int {
+	n := 0
+
+	for 0 < written && n < len(data) {
+		if cmdIAC == data[n] {
+			if written < 2 {
+				break
+			}
+			written -= 2
 		} else {
-			buffer.WriteByte(datum) // The returned error is always nil, so we ignore it.
+			written--
 		}
+		n++
+	}
+
+	return n
+}
+
+
+// WriteEndOfRecord marks the end of a record, such as a prompt.
+//
+// If END-OF-RECORD (RFC 885) was agreed to, then this writes an IAC EOR. Else, if
+// SUPPRESS-GO-AHEAD (RFC 858) was not agreed to, then this writes an IAC GA.
+// Else, there is nothing to write.
+func (w *internalDataWriter) WriteEndOfRecord() error {
+	if nil == w.negotiator {
+		return nil
 	}
 
-	if buffer.Len() > 0 {
-		var numWritten int64
-		numWritten, err = oi.LongWrite(w.wrapped, buffer.Bytes())
-		n += numWritten
+	var p []byte
+	switch {
+	case w.negotiator.localEnabled(optionEndOfRecord):
+		p = []byte{cmdIAC, cmdEOR}
+	case !w.negotiator.localEnabled(optionSuppressGoAhead):
+		p = []byte{cmdIAC, cmdGA}
+	default:
+		return nil
 	}
 
-	return n, err
+	_, err := oi.LongWrite(w.wrapped, p)
+	return err
 }
@@ -3,6 +3,8 @@ package telnet
 
 import (
 	"bytes"
+	"errors"
+	"io"
 
 	"testing"
 )
@@ -114,3 +116,206 @@ func TestDataWriter(t *testing.T) {
 		}
 	}
 }
+
+
+func TestDataWriterEndOfRecord(t *testing.T) {
+
+	tests := []struct{
+		Negotiation []byte
+		Expected    []byte
+	}{
+		{
+			Negotiation: []byte{},
+			Expected:    []byte{255,249}, // IAC GA
+		},
+		{
+			Negotiation: []byte{255,253,25}, // IAC DO END-OF-RECORD
+			Expected:    []byte{255,251,25,   255,239}, // IAC WILL END-OF-RECORD IAC EOR
+		},
+		{
+			Negotiation: []byte{255,253,3}, // IAC DO SUPPRESS-GO-AHEAD
+			Expected:    []byte{255,251,3},
+		},
+		{
+			Negotiation: []byte{255,253,3,   255,253,25}, // IAC DO SUPPRESS-GO-AHEAD IAC DO END-OF-RECORD
+			Expected:    []byte{255,251,3,   255,251,25,   255,239},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		negotiator := newNegotiator(&buffer, newContext(), true)
+		reader := newNegotiatingDataReader(bytes.NewReader(test.Negotiation), negotiator)
+		reader.Read(make([]byte, 1))
+
+		writer := newNegotiatingDataWriter(&buffer, negotiator)
+		if err := writer.WriteEndOfRecord(); nil != err {
+			t.Errorf("For test #%d, did not expected an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+
+
+	var buffer bytes.Buffer
+	if err := newDataWriter(&buffer).WriteEndOfRecord(); nil != err {
+		t.Errorf("Did not expected an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+type limitedWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+var errLimitedWriterFull = errors.New("Full")
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	room := w.limit - w.Len()
+	if len(p) <= room {
+		return w.Buffer.Write(p)
+	}
+
+	n, _ := w.Buffer.Write(p[:room])
+	return n, errLimitedWriterFull
+}
+
+
+func TestDataWriterPartialWrite(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Limit    int
+		Expected int
+	}{
+		{
+			Bytes:    []byte("apple"),
+			Limit:    3,
+			Expected: 3,
+		},
+		{
+			Bytes:    []byte("ap\xffple"),
+			Limit:    3, // "ap" + half of the escaped IAC.
+			Expected: 2,
+		},
+		{
+			Bytes:    []byte("ap\xffple"),
+			Limit:    4, // "ap" + all of the escaped IAC.
+			Expected: 3,
+		},
+		{
+			Bytes:    []byte("ap\xffple"),
+			Limit:    5,
+			Expected: 4,
+		},
+		{
+			Bytes:    []byte{255,255,255},
+			Limit:    5,
+			Expected: 2,
+		},
+		{
+			Bytes:    bytes.Repeat([]byte("a\xff"), 2000),
+			Limit:    2999,
+			Expected: 1999,
+		},
+		{
+			Bytes:    bytes.Repeat([]byte("a"), 5000),
+			Limit:    4321,
+			Expected: 4321,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		subWriter := &limitedWriter{limit:test.Limit}
+
+		writer := newDataWriter(subWriter)
+
+		n, err := writer.Write(test.Bytes)
+		if errLimitedWriterFull != err {
+			t.Errorf("For test #%d, expected error %v, but actually got: (%T) %v", testNumber, errLimitedWriterFull, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, n; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestDataWriterLong(t *testing.T) {
+
+	for _, p := range [][]byte{
+		bytes.Repeat([]byte("apple banana cherry "), 1000),
+		bytes.Repeat([]byte("apple\xffbanana cherry "), 1000),
+		bytes.Repeat([]byte{255}, 5000),
+		append(bytes.Repeat([]byte("x"), 300), bytes.Repeat([]byte("\xffy"), 2000)...),
+	} {
+		var buffer bytes.Buffer
+
+		n, err := newDataWriter(&buffer).Write(p)
+		if nil != err {
+			t.Errorf("Did not expected an error, but actually got one: (%T) %v", err, err)
+			continue
+		}
+		if expected, actual := len(p), n; expected != actual {
+			t.Errorf("Expected %d, but actually got %d.", expected, actual)
+			continue
+		}
+
+		if expected, actual := string(bytes.Replace(p, []byte{255}, []byte{255,255}, -1)), buffer.String(); expected != actual {
+			t.Errorf("Expected %d bytes, but actually got %d bytes (that did not match).", len(expected), len(actual))
+			continue
+		}
+	}
+}
+
+
+func benchmarkDataWriter(b *testing.B, p []byte) {
+	writer := newDataWriter(io.Discard)
+
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := writer.Write(p); nil != err {
+			b.Fatalf("Did not expected an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+}
+
+
+func BenchmarkDataWriterNoIAC(b *testing.B) {
+	p := bytes.Repeat([]byte("apple banana cherry "), 1638) // ~32KB
+
+	benchmarkDataWriter(b, p)
+}
+
+
+func BenchmarkDataWriterSomeIAC(b *testing.B) {
+	p := bytes.Repeat([]byte("apple\xffbanana cherry "), 1560) // ~32KB, with an IAC every 21 bytes.
+
+	benchmarkDataWriter(b, p)
+}
+
+
+func BenchmarkDataWriterAllIAC(b *testing.B) {
+	p := bytes.Repeat([]byte{255}, 32768)
+
+	benchmarkDataWriter(b, p)
+}
@@ -0,0 +1,164 @@
+package telnet
+
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+
+var errUnsupportedNetwork = errors.New("Unsupported network; must be \"tcp\", \"tcp4\", \"tcp6\" or \"unix\".")
+
+
+// A Dialer contains options for making TELNET (or TELNETS) client connections.
+//
+// It mirrors net.Dialer. If TLSConfig is set, then it makes (secure) TELNETS connections;
+// else it makes (un-secure) TELNET connections.
+//
+// The zero value is a Dialer with no timeout that makes TELNET connections.
+//
+// For example:
+//
+//	dialer := telnet.Dialer{
+//		Timeout: 10 * time.Second,
+//	}
+//	
+//	conn, err := dialer.DialContext(ctx, "tcp", "example.net:telnet")
+//	if nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	defer conn.Close()
+type Dialer struct {
+	Timeout   time.Duration // maximum amount of time a dial (including any TLS handshake) will wait for; no timeout if zero.
+	KeepAlive time.Duration // keep-alive period; see net.Dialer.
+	LocalAddr net.Addr      // local address to use when dialing; optional.
+	Resolver  *net.Resolver // optional alternate resolver.
+
+	TLSConfig *tls.Config // optional TLS configuration; if not nil, then TELNETS is used.
+
+	// Proxy returns the proxy to connect through for the address it is given. A nil URL
+	// means connect directly. Supported proxy schemes are "socks5", "socks5h" and "http"
+	// (for HTTP CONNECT); a username and password in the URL are used to authenticate.
+	//
+	// If Proxy is nil, then ProxyFromEnvironment is used. (To never use a proxy, set it
+	// to ProxyURL(nil).)
+	Proxy func(addr string) (*url.URL, error)
+
+	Logger Logger
+}
+
+
+// Dial connects to the address 'addr' on the network 'network'.
+//
+// See DialContext.
+func (dialer *Dialer) Dial(network string, addr string) (*Conn, error) {
+	return dialer.DialContext(context.Background(), network, addr)
+}
+
+
+// DialContext connects to the address 'addr' on the network 'network', using the context 'ctx'.
+//
+// 'network' must be "tcp", "tcp4", "tcp6" or "unix". (If it is empty, "tcp" is used.)
+//
+// If 'addr' is empty, and 'network' is not "unix", then the system's 'loopback address' is
+// used; i.e., "127.0.0.1:telnet", or "127.0.0.1:telnets" if TLSConfig is set.
+//
+// If 'ctx' is canceled (or its deadline passes) before the connection is complete, then
+// DialContext gives up and returns an error. This covers connecting, talking to any proxy,
+// and the TLS handshake.
+// Once DialContext returns, 'ctx' no longer has any effect on the connection.
+func (dialer *Dialer) DialContext(ctx context.Context, network string, addr string) (*Conn, error) {
+	return dialer.dial(ctx, network, addr, &ConnConfig{Logger: dialer.Logger})
+}
+
+
+func (dialer *Dialer) dial(ctx context.Context, network string, addr string, config *ConnConfig) (*Conn, error) {
+
+	switch network {
+	case "":
+		network = "tcp"
+	case "tcp", "tcp4", "tcp6", "unix":
+		// Nothing to do.
+	default:
+		return nil, errUnsupportedNetwork
+	}
+
+	if "" == addr && "unix" != network {
+		if nil == dialer.TLSConfig {
+			addr = "127.0.0.1:telnet"
+		} else {
+			addr = "127.0.0.1:telnets"
+		}
+	}
+
+	netDialer := net.Dialer{
+		Timeout:   dialer.Timeout,
+		KeepAlive: dialer.KeepAlive,
+		LocalAddr: dialer.LocalAddr,
+		Resolver:  dialer.Resolver,
+	}
+
+	if 0 < dialer.Timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+
+	var proxyURL *url.URL
+	if "unix" != network {
+		proxy := dialer.Proxy
+		if nil == proxy {
+			proxy = ProxyFromEnvironment
+		}
+
+		var err error
+		proxyURL, err = proxy(addr)
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	var conn net.Conn
+	var err error
+
+	if nil == proxyURL {
+		conn, err = netDialer.DialContext(ctx, network, addr)
+	} else {
+		conn, err = dialProxy(ctx, &netDialer, network, proxyURL, addr)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if nil != dialer.TLSConfig {
+		tlsConfig := dialer.TLSConfig
+		if "" == tlsConfig.ServerName && "unix" != network {
+			host, _, err := net.SplitHostPort(addr)
+			if nil != err {
+				conn.Close()
+				return nil, err
+			}
+
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); nil != err {
+			conn.Close()
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	telnetConn := NewConn(conn, config)
+	telnetConn.addr = addr
+
+	return telnetConn, nil
+}
@@ -0,0 +1,156 @@
+package telnet
+
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"testing"
+)
+
+
+func TestDialerDialContext(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, EchoHandler)
+
+	dialer := Dialer{
+		Timeout: 5 * time.Second,
+	}
+
+	for _, network := range []string{"", "tcp", "tcp4"} {
+		conn, err := dialer.DialContext(context.Background(), network, listener.Addr().String())
+		if nil != err {
+			t.Errorf("For network %q, did not expect an error, but actually got one: (%T) %v", network, err, err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+
+func TestDialerUnix(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "telnet")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "telnet.sock")
+
+	listener, err := net.Listen("unix", path)
+	if nil != err {
+		t.Skipf("Unix sockets not available: %v", err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, EchoHandler)
+
+	var dialer Dialer
+
+	conn, err := dialer.Dial("unix", path)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	conn.Close()
+}
+
+
+func TestDialerDialedAddr(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, EchoHandler)
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// The address that was dialed; not the one the name resolved to.
+	addr := net.JoinHostPort("localhost", port)
+
+	dialer := Dialer{
+		Proxy: ProxyURL(nil),
+	}
+
+	conn, err := dialer.Dial("tcp4", addr)
+	if nil != err {
+		t.Skipf("Could not dial %q: %v", addr, err)
+	}
+	defer conn.Close()
+
+	if expected, actual := addr, conn.dialedAddr(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// A Conn that was not made by a Dialer falls back to the remote address.
+	wrapped := NewConn(conn.conn, nil)
+	if expected, actual := conn.RemoteAddr().String(), wrapped.dialedAddr(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestDialerUnsupportedNetwork(t *testing.T) {
+
+	var dialer Dialer
+
+	if _, err := dialer.Dial("udp", "127.0.0.1:23"); errUnsupportedNetwork != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", errUnsupportedNetwork, err, err)
+	}
+}
+
+
+func TestDialerCanceledDuringHandshake(t *testing.T) {
+
+	// This listener accepts connections, but never does the TLS handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	dialer := Dialer{
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+
+	_, err = dialer.DialContext(ctx, "tcp", listener.Addr().String())
+	if nil == err {
+		t.Fatalf("Expected an error, but did not actually get one.")
+	}
+
+	if elapsed := time.Since(began); 5 * time.Second < elapsed {
+		t.Errorf("Expected the canceled dial to return quickly, but it took %v.", elapsed)
+	}
+}
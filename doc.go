@@ -71,6 +71,29 @@ DialToAndCallTLS creates a (secure) TELNETS client, which connects to a given ad
 	}
 
 
+Reporting Errors From A Client:
+
+A caller can report errors by being a telnet.CallerFunc (or anything else that is a telnet.ErrorCaller).
+The error it returns is returned from DialToAndCall (and the rest of the DialAndCall family).
+
+	caller := telnet.CallerFunc(func(ctx telnet.Context, w telnet.Writer, r telnet.Reader) error {
+		if _, err := w.Write([]byte("show version\r\n")); nil != err {
+			return err
+		}
+
+		//@TODO: Read the response.
+
+		return nil
+	})
+
+	if err := telnet.DialToAndCall("example.net:23", caller); nil != err {
+		//@TODO: Handle error.
+	}
+
+To also find out why a session ended (local EOF, remote close, protocol error, or timeout), use
+Client.CallSession, which returns a telnet.SessionResult.
+
+
 TELNET vs TELNETS
 
 If you are communicating over the open Internet, you should be using (the secure) TELNETS protocol and ListenAndServeTLS.
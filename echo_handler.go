@@ -16,7 +16,7 @@ type internalEchoHandler struct{}
 
 func (handler internalEchoHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
 
-	var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
+	var buffer [1024]byte
 	p := buffer[:]
 
 	for {
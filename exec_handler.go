@@ -0,0 +1,151 @@
+package telnet
+
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+
+var errExecNoPath = errors.New("ExecHandler has no Path.")
+
+
+// execTerminalTypeWait is how long ExecHandler waits for the client to send its terminal type
+// (with TERMINAL-TYPE), before it starts the program without it.
+const execTerminalTypeWait = 2 * time.Second
+
+
+// ExecHandler is a Handler that runs a program for each connection, on a pseudo-terminal (pty);
+// much like a classic telnetd runs login. (This is only supported on Linux.)
+//
+// For example:
+//
+//	handler := &telnet.ExecHandler{
+//		Path: "/usr/local/bin/console",
+//		Args: []string{"--safe"},
+//		Dir:  "/var/lib/console",
+//	}
+//
+//	err := telnet.ListenAndServe(":5555", handler)
+//
+// The client is asked to do the TERMINAL-TYPE and NAWS options, and the program is started once
+// the client has sent its terminal type (or refused to). The TERM environment variable is set
+// to the terminal type; and the size of the pty follows the client's window size, as it changes.
+//
+// The server does the ECHO and SUPPRESS-GO-AHEAD options, so that the pty (i.e., the program)
+// does the echoing, and what the user types is sent a character at a time.
+//
+// An IP (interrupt process) from the client sends SIGINT, and a BRK (break) sends SIGQUIT, to
+// the process group in the foreground of the pty.
+//
+// ServeTELNET returns when the program exits. If the client goes away first, then the program
+// is sent SIGHUP (just as if a terminal was hung up), and ServeTELNET waits for it to exit.
+type ExecHandler struct {
+	Path string   // the program to run.
+	Args []string // the arguments to the program (not including the program name itself).
+
+	// Env is the environment of the program, with entries of the form "KEY=value". If Env is
+	// nil, then the program gets the server's environment. Either way, TERM is set to the
+	// client's terminal type, if it sent one.
+	Env []string
+
+	Dir string // the working directory of the program; the server's if empty.
+
+	// Credential, if not nil, is the user (and group) to run the program as. (Which the server
+	// has to be allowed to do; i.e., usually the server has to run as root.)
+	Credential *ExecCredential
+}
+
+
+// An ExecCredential is a user ID and group ID to run an ExecHandler program as.
+type ExecCredential struct {
+	UID uint32
+	GID uint32
+}
+
+
+// ServeTELNET runs the program for the connection.
+func (handler *ExecHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	if "" == handler.Path {
+		logger.Error(errExecNoPath)
+		return
+	}
+
+	if err := handler.serve(ctx, w, r); nil != err {
+		logger.Errorf("Problem running %q: %v", handler.Path, err)
+	}
+}
+
+
+// execNegotiate offers the options an ExecHandler wants: the server does ECHO and
+// SUPPRESS-GO-AHEAD (so the pty echoes, and the client sends a character at a time), and the
+// client is asked for its terminal type and window size.
+func execNegotiate(conn *Conn) error {
+	for _, option := range []byte{optionEcho, optionSuppressGoAhead} {
+		if err := conn.negotiator.offerLocal(option); nil != err {
+			return err
+		}
+	}
+
+	for _, option := range []byte{optionSuppressGoAhead, optionTerminalType, optionWindowSize} {
+		if err := conn.negotiator.offerRemote(option); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// environ returns the environment for the program; i.e., Env (or the server's environment),
+// with TERM set to the terminal type in 'ctx' (if there is one).
+func (handler *ExecHandler) environ(ctx Context) []string {
+	env := handler.Env
+	if nil == env {
+		env = os.Environ()
+	}
+
+	terminalType := terminalContext(ctx).TerminalType()
+	if "" == terminalType {
+		return env
+	}
+
+	environ := make([]string, 0, len(env)+1)
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, "TERM=") {
+			environ = append(environ, entry)
+		}
+	}
+
+	return append(environ, "TERM="+strings.ToLower(terminalType))
+}
+
+
+// translateNVTInput turns the line endings in 'p' (from a client that is not in binary mode)
+// into what a terminal sends: CR LF and CR NUL become just CR. (Which the pty turns into LF, if
+// the program wants it to.)
+//
+// 'lastCR' is whether the data before 'p' ended with a CR; translateNVTInput returns what 'p'
+// became, and whether it ended with a CR.
+func translateNVTInput(p []byte, lastCR bool) ([]byte, bool) {
+	translated := p[:0]
+
+	for _, b := range p {
+		if lastCR && ('\n' == b || 0 == b) {
+			lastCR = false
+			continue
+		}
+
+		translated = append(translated, b)
+		lastCR = '\r' == b
+	}
+
+	return translated, lastCR
+}
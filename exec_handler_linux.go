@@ -0,0 +1,290 @@
+//go:build linux
+// +build linux
+
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+
+// execDrainWait is how long ExecHandler keeps sending what is left of the program's output,
+// after it exits. (Something it started in the background could keep the pty open forever.)
+const execDrainWait = 1 * time.Second
+
+// execHangUpWait is how long ExecHandler waits for the program to exit, after hanging it up,
+// before it kills it.
+const execHangUpWait = 5 * time.Second
+
+
+// An execProcess is the program an ExecHandler started, and its pty.
+type execProcess struct {
+	pty *os.File // the master side.
+
+	mutex  sync.Mutex
+	pid    int // zero until the program has started.
+	exited bool
+}
+
+
+// signalForeground sends 'sig' to the process group in the foreground of the pty; if the
+// program is running.
+func (process *execProcess) signalForeground(sig syscall.Signal) error {
+	process.mutex.Lock()
+	defer process.mutex.Unlock()
+
+	if 0 == process.pid || process.exited {
+		return nil
+	}
+
+	return control(process.pty, func(fd int) error {
+		pgrp, err := foregroundProcessGroup(fd)
+		if nil != err {
+			return err
+		}
+
+		return syscall.Kill(-pgrp, sig)
+	})
+}
+
+
+// signalGroup sends 'sig' to the program's process group; if the program is running. (SIGHUP is
+// what it gets when the terminal is hung up.)
+func (process *execProcess) signalGroup(sig syscall.Signal) error {
+	process.mutex.Lock()
+	defer process.mutex.Unlock()
+
+	if 0 == process.pid || process.exited {
+		return nil
+	}
+
+	return syscall.Kill(-process.pid, sig)
+}
+
+
+// resize sets the size of the pty to the window size in 'ctx'; if it is known.
+func (process *execProcess) resize(ctx Context) error {
+	width, height := terminalContext(ctx).WindowSize()
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	return control(process.pty, func(fd int) error {
+		return setWindowSize(fd, width, height)
+	})
+}
+
+
+func (handler *ExecHandler) serve(ctx Context, w Writer, r Reader) error {
+
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	pty, tty, err := openPty()
+	if nil != err {
+		return err
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	process := execProcess{
+		pty: pty,
+	}
+
+
+	// If this is a *Conn, then ask for the terminal type and window size, and follow along as
+	// they (and IPs and BRKs) arrive.
+	terminalType := make(chan struct{}, 1)
+
+	conn, _ := r.(*Conn)
+	if nil != conn {
+		conn.negotiator.setHooks(
+			func(option byte) {
+				switch option {
+				case optionTerminalType:
+					select {
+					case terminalType <- struct{}{}:
+					default:
+					}
+				case optionWindowSize:
+					if err := process.resize(ctx); nil != err {
+						logger.Warnf("Problem setting the pty window size: %v", err)
+					}
+				}
+			},
+			func(command byte) {
+				var err error
+				switch command {
+				case cmdIP:
+					err = process.signalForeground(syscall.SIGINT)
+				case cmdBRK:
+					err = process.signalForeground(syscall.SIGQUIT)
+				}
+				if nil != err {
+					logger.Warnf("Problem signaling the program: %v", err)
+				}
+			},
+		)
+		defer conn.negotiator.setHooks(nil, nil)
+
+		if err := execNegotiate(conn); nil != err {
+			return err
+		}
+	}
+
+
+	// Copy what the client sends to the pty. (Starting before the program does, so that the
+	// negotiation above gets answered.)
+	hungUp := make(chan struct{})
+	go func() {
+		defer close(hungUp)
+
+		var lastCR bool
+
+		var buffer [1024]byte
+		for {
+			n, err := r.Read(buffer[:])
+
+			p := buffer[:n]
+			if nil == conn || !conn.negotiator.remoteEnabled(optionBinary) {
+				p, lastCR = translateNVTInput(p, lastCR)
+			}
+
+			if 0 < len(p) {
+				if _, err := oi.LongWrite(pty, p); nil != err {
+					return
+				}
+			}
+
+			if nil != err {
+				return
+			}
+		}
+	}()
+
+
+	if nil != conn && "" == terminalContext(ctx).TerminalType() {
+		timer := time.NewTimer(execTerminalTypeWait)
+		select {
+		case <-terminalType:
+		case <-timer.C:
+			logger.Debug("Gave up waiting for the terminal type.")
+		case <-hungUp:
+		}
+		timer.Stop()
+	}
+
+	if err := process.resize(ctx); nil != err {
+		logger.Warnf("Problem setting the pty window size: %v", err)
+	}
+
+
+	cmd := exec.Command(handler.Path, handler.Args...)
+	cmd.Dir = handler.Dir
+	cmd.Env = handler.environ(ctx)
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    0, // i.e., stdin.
+	}
+	if nil != handler.Credential {
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid: handler.Credential.UID,
+			Gid: handler.Credential.GID,
+		}
+	}
+
+	process.mutex.Lock()
+	err = cmd.Start()
+	if nil == err {
+		process.pid = cmd.Process.Pid
+	}
+	process.mutex.Unlock()
+	if nil != err {
+		oi.LongWriteString(w, "Could not start the program.\r\n")
+		return err
+	}
+	logger.Debugf("Started %q (pid %d).", handler.Path, cmd.Process.Pid)
+
+	// Only the program should have the tty open; so that reading the pty fails once it (and
+	// anything it started) exits.
+	tty.Close()
+
+
+	// Copy what the program writes to the client.
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+
+		var buffer [1024]byte
+		for {
+			n, err := pty.Read(buffer[:])
+			if 0 < n {
+				if _, err := oi.LongWrite(w, buffer[:n]); nil != err {
+					return
+				}
+			}
+			if nil != err {
+				return
+			}
+		}
+	}()
+
+
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+
+		process.mutex.Lock()
+		process.exited = true
+		process.mutex.Unlock()
+
+		exited <- err
+	}()
+
+	select {
+	case err = <-exited:
+	case <-hungUp:
+		logger.Debugf("The client went away; hanging up %q.", handler.Path)
+		if err := process.signalGroup(syscall.SIGHUP); nil != err {
+			logger.Warnf("Problem hanging up the program: %v", err)
+		}
+
+		timer := time.NewTimer(execHangUpWait)
+		select {
+		case err = <-exited:
+		case <-timer.C:
+			logger.Warnf("%q did not exit after being hung up; killing it.", handler.Path)
+			process.signalGroup(syscall.SIGKILL)
+			err = <-exited
+		}
+		timer.Stop()
+	}
+	logger.Debugf("%q exited: %v", handler.Path, err)
+
+	timer := time.NewTimer(execDrainWait)
+	select {
+	case <-drained:
+	case <-timer.C:
+	}
+	timer.Stop()
+
+	if _, ok := err.(*exec.ExitError); ok {
+		// The program exiting with a non-zero status is not a problem with serving it.
+		return nil
+	}
+
+	return err
+}
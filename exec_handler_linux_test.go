@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package telnet
+
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+func TestExecHandler(t *testing.T) {
+
+	pty, tty, err := openPty()
+	if nil != err {
+		t.Skipf("Cannot open a pty: %v", err)
+	}
+	pty.Close()
+	tty.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := &ExecHandler{
+		Path: "/bin/sh",
+		Args: []string{"-c", `echo "term=$TERM"; stty size; trap 'echo caught; exit 3' INT; echo ready; while :; do sleep 0.05; done`},
+		Env:  []string{"PATH=/bin:/usr/bin", "TERM=dumb"},
+	}
+
+	go Serve(listener, handler)
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	conn.Context().InjectTerminalType("XTERM-256color")
+	conn.Context().InjectWindowSize(100, 40)
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var output bytes.Buffer
+	for !strings.Contains(output.String(), "ready") {
+		var buffer [256]byte
+		n, err := conn.Read(buffer[:])
+		output.Write(buffer[:n])
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v; after %q", err, err, output.String())
+		}
+	}
+
+	if err := conn.negotiator.sendCommand(cmdIP); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	rest, err := ioutil.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	output.Write(rest)
+
+	for _, expected := range []string{"term=xterm-256color\r\n", "40 100\r\n", "caught\r\n"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected the output to contain %q, but actually got %q.", expected, output.String())
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"errors"
+)
+
+
+var errExecNotSupported = errors.New("ExecHandler is not supported on this system.")
+
+
+func (handler *ExecHandler) serve(ctx Context, w Writer, r Reader) error {
+	oi.LongWriteString(w, "Could not start the program.\r\n")
+	return errExecNotSupported
+}
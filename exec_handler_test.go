@@ -0,0 +1,40 @@
+package telnet
+
+
+import (
+	"testing"
+)
+
+
+func TestTranslateNVTInput(t *testing.T) {
+
+	tests := []struct{
+		Data           string
+		LastCR         bool
+		Expected       string
+		ExpectedLastCR bool
+	}{
+		{Data: "ls -l\r\n",   Expected: "ls -l\r"},
+		{Data: "ls -l\r\x00", Expected: "ls -l\r"},
+		{Data: "ls -l\r",     Expected: "ls -l\r", ExpectedLastCR: true},
+		{Data: "\nls\n",      LastCR: true, Expected: "ls\n"},
+		{Data: "\x00",        LastCR: true, Expected: ""},
+		{Data: "a\r\rb\r\n",  Expected: "a\r\rb\r"},
+		{Data: "",            LastCR: true, Expected: "", ExpectedLastCR: true},
+	}
+
+
+	for testNumber, test := range tests {
+
+		translated, lastCR := translateNVTInput([]byte(test.Data), test.LastCR)
+
+		if expected, actual := test.Expected, string(translated); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := test.ExpectedLastCR, lastCR; expected != actual {
+			t.Errorf("For test #%d, expected lastCR %t, but actually got %t.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Package expect provides "expect"-style scripting (for the telnet package) for TELNET and TELNETS clients.
+
+I.e., it lets you wait for some text (such as "login:") to be received, and then send a reply (such as
+a user name), without having to write the loops that read bytes and look for that text yourself.
+
+
+Example
+
+Here is an example usage:
+
+	package main
+	
+	import (
+		"github.com/reiver/go-telnet"
+		"github.com/reiver/go-telnet/expect"
+	
+		"fmt"
+		"regexp"
+		"time"
+	)
+	
+	func main() {
+	
+		conn, err := telnet.DialTo("example.net:23")
+		if nil != err {
+			panic(err)
+		}
+		session := expect.NewSession(conn, conn)
+		defer session.Close() // (which closes 'conn' too.)
+		session.Timeout = 10 * time.Second
+	
+		if _, err := session.Expect(expect.String("login:"), 0); nil != err {
+			panic(err)
+		}
+		session.SendLine("joeblow")
+	
+		if _, err := session.Expect(expect.String("Password:"), 0); nil != err {
+			panic(err)
+		}
+		session.SendLine("password123")
+	
+		match, err := session.ExpectAny(0, expect.Regexp(regexp.MustCompile(`\$ $`)), expect.String("Login incorrect"))
+		if nil != err {
+			panic(err)
+		}
+		if 1 == match.Index {
+			fmt.Println("Could not log in.")
+			return
+		}
+	
+		session.SendLine("uptime")
+	
+		match, err = session.Expect(expect.Regexp(regexp.MustCompile(`\$ $`)), 5 * time.Second)
+		if nil != err {
+			panic(err)
+		}
+	
+		fmt.Printf("uptime said: %q\n", match.Before)
+	}
+
+
+Since a Session only needs a telnet.Writer and a telnet.Reader, it can also be used from within
+a telnet.Caller.
+
+
+Running Commands
+
+For network devices (such as routers and switches), and anything else with a command-line prompt,
+a Runner can log in, and then run commands and return just their output. (I.e., without the echoed
+command, without the prompt, and with any "--More--" pagers dealt with.)
+
+	runner := expect.NewRunner(expect.NewSession(conn, conn), regexp.MustCompile(`[>#] ?$`))
+	
+	if err := runner.Login("admin", "password123"); nil != err {
+		panic(err)
+	}
+	
+	output, err := runner.Run("show version")
+	if nil != err {
+		panic(err)
+	}
+
+
+Transcript
+
+A Session keeps a transcript of everything it has read, which is available from the Transcript method.
+(This can be handy when something did not match, and you want to see what was actually received.)
+*/
+package expect
@@ -0,0 +1,63 @@
+package expect
+
+
+import (
+	"bytes"
+	"regexp"
+)
+
+
+// A Pattern is something that a Session can wait for.
+//
+// Use String or Regexp to create a Pattern.
+type Pattern interface {
+	// find returns the location of the first match in 'p'; i.e., p[loc[0]:loc[1]]. Any
+	// sub-matches follow, in pairs, like with regexp.Regexp.FindSubmatchIndex. A nil
+	// 'loc' means no match.
+	find(p []byte) (loc []int)
+	String() string
+}
+
+
+// String returns a Pattern that matches the literal text 's'.
+func String(s string) Pattern {
+	return literalPattern(s)
+}
+
+
+type literalPattern string
+
+func (pattern literalPattern) find(p []byte) []int {
+	i := bytes.Index(p, []byte(pattern))
+	if i < 0 {
+		return nil
+	}
+
+	return []int{i, i + len(pattern)}
+}
+
+func (pattern literalPattern) String() string {
+	return string(pattern)
+}
+
+
+// Regexp returns a Pattern that matches the regular expression 're'.
+//
+// Note that data is matched as it arrives. So, for example, `\d+` might match just
+// the first digits of a number, if the rest of them have not been received yet.
+func Regexp(re *regexp.Regexp) Pattern {
+	return regexpPattern{re}
+}
+
+
+type regexpPattern struct {
+	re *regexp.Regexp
+}
+
+func (pattern regexpPattern) find(p []byte) []int {
+	return pattern.re.FindSubmatchIndex(p)
+}
+
+func (pattern regexpPattern) String() string {
+	return pattern.re.String()
+}
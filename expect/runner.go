@@ -0,0 +1,217 @@
+package expect
+
+
+import (
+	"github.com/reiver/go-telnet/internal/prompt"
+
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+
+// ErrLoginFailed is returned by Runner.Login when the other side rejects the user name or password.
+var ErrLoginFailed = errors.New("Login failed.")
+
+
+var errNoPrompt = errors.New("Runner has no Prompt.")
+
+
+var (
+	defaultPager          = regexp.MustCompile(` *-+ ?\(?[Mm][Oo][Rr][Ee].*?-+\s*$| *<--- More --->\s*$`)
+	defaultLoginPrompt    = prompt.Login
+	defaultPasswordPrompt = prompt.Password
+	defaultLoginFailure   = prompt.LoginFailure
+
+	// pagerErase matches what devices commonly send to erase a pager prompt, after it is answered.
+	pagerErase = regexp.MustCompile("^(?:\x08+ *\x08*|\r +\r|\x1b\\[K)")
+)
+
+
+// A Runner runs commands on network devices (such as routers and switches), or anything else
+// with a command-line prompt, and returns their output.
+//
+// For example:
+//
+//	runner := expect.NewRunner(expect.NewSession(conn, conn), regexp.MustCompile(`[>#] ?$`))
+//	runner.Timeout = 30 * time.Second
+//	
+//	if err := runner.Login("admin", "password123"); nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	
+//	output, err := runner.Run("show version")
+type Runner struct {
+	Session *Session
+
+	Prompt *regexp.Regexp // the command-line prompt; e.g., `[>#] ?$`. It should match at the end of what was received.
+
+	Pager      *regexp.Regexp // the pager prompt (such as "--More--"); a common set of pager prompts if nil.
+	PagerReply string         // what to send to the pager prompt to get more; " " if empty.
+
+	LoginPrompt    *regexp.Regexp // used by Login; matches "login:" or "Username:" (and the like) if nil.
+	PasswordPrompt *regexp.Regexp // used by Login; matches "Password:" if nil.
+	LoginFailure   *regexp.Regexp // used by Login; matches a line that is just "Login incorrect", "Access denied" (and the like) if nil.
+
+	Timeout time.Duration // how long to wait for each prompt (or pager prompt); the Session's Timeout if zero.
+}
+
+
+// NewRunner returns a new Runner, which uses 'session', and which waits for 'prompt'.
+func NewRunner(session *Session, prompt *regexp.Regexp) *Runner {
+	runner := Runner{
+		Session:session,
+		Prompt:prompt,
+	}
+
+	return &runner
+}
+
+
+// Login waits for the login prompt, sends 'user', waits for the password prompt (unless the
+// command-line prompt comes first), sends 'password', and then waits for the command-line prompt.
+//
+// If the other side says the login failed (and then asks to log in again, or hangs up), then
+// Login returns ErrLoginFailed. (If the command-line prompt comes after something that looks
+// like a login failure, such as in a message of the day, then the login worked.)
+func (runner *Runner) Login(user string, password string) error {
+
+	if nil == runner.Prompt {
+		return errNoPrompt
+	}
+
+	loginPrompt := runner.LoginPrompt
+	if nil == loginPrompt {
+		loginPrompt = defaultLoginPrompt
+	}
+
+	passwordPrompt := runner.PasswordPrompt
+	if nil == passwordPrompt {
+		passwordPrompt = defaultPasswordPrompt
+	}
+
+	loginFailure := runner.LoginFailure
+	if nil == loginFailure {
+		loginFailure = defaultLoginFailure
+	}
+
+	if _, err := runner.Session.Expect(Regexp(loginPrompt), runner.Timeout); nil != err {
+		return err
+	}
+	if err := runner.Session.SendLine(user); nil != err {
+		return err
+	}
+
+	match, err := runner.Session.ExpectAny(runner.Timeout, Regexp(passwordPrompt), Regexp(runner.Prompt), Regexp(loginFailure))
+	if nil != err {
+		return err
+	}
+	switch match.Index {
+	case 1:
+		// No password needed.
+		return nil
+	case 2:
+		return runner.loginFailed(loginPrompt, passwordPrompt)
+	}
+
+	if err := runner.Session.SendLine(password); nil != err {
+		return err
+	}
+
+	match, err = runner.Session.ExpectAny(runner.Timeout, Regexp(runner.Prompt), Regexp(loginFailure), Regexp(loginPrompt))
+	if nil != err {
+		return err
+	}
+	switch match.Index {
+	case 1:
+		return runner.loginFailed(loginPrompt, passwordPrompt)
+	case 2:
+		return ErrLoginFailed
+	}
+
+	return nil
+}
+
+
+// loginFailed is called by Login when what looks like a login failure was received. It only
+// counts as one if the login (or password) prompt comes next, or the other side hangs up (or
+// goes quiet). If the command-line prompt comes next, then the login worked after all.
+func (runner *Runner) loginFailed(loginPrompt *regexp.Regexp, passwordPrompt *regexp.Regexp) error {
+	match, err := runner.Session.ExpectAny(runner.Timeout, Regexp(runner.Prompt), Regexp(loginPrompt), Regexp(passwordPrompt))
+	if nil != err || 0 != match.Index {
+		return ErrLoginFailed
+	}
+
+	return nil
+}
+
+
+// Run sends 'command', and waits for the command-line prompt. It returns the output of the
+// command; i.e., what was received after the echoed command, and before the prompt.
+//
+// Pager prompts (such as "--More--") are answered automatically, and removed from the output.
+// The line endings in the output are "\n" (rather than "\r\n").
+func (runner *Runner) Run(command string) (output string, err error) {
+
+	if nil == runner.Prompt {
+		return "", errNoPrompt
+	}
+
+	pager := runner.Pager
+	if nil == pager {
+		pager = defaultPager
+	}
+
+	pagerReply := runner.PagerReply
+	if "" == pagerReply {
+		pagerReply = " "
+	}
+
+	if err := runner.Session.SendLine(command); nil != err {
+		return "", err
+	}
+
+	var builder strings.Builder
+	afterPager := false
+
+	for {
+		match, err := runner.Session.ExpectAny(runner.Timeout, Regexp(runner.Prompt), Regexp(pager))
+		if nil != err {
+			return "", err
+		}
+
+		before := match.Before
+		if afterPager {
+			before = pagerErase.ReplaceAllString(before, "")
+		}
+		builder.WriteString(before)
+
+		if 0 == match.Index {
+			break
+		}
+
+		if err := runner.Session.Send(pagerReply); nil != err {
+			return "", err
+		}
+		afterPager = true
+	}
+
+	return cleanOutput(builder.String(), command), nil
+}
+
+
+// cleanOutput removes the echoed 'command' from the start of 'output', and turns "\r\n"
+// line endings into "\n".
+func cleanOutput(output string, command string) string {
+
+	output = strings.Replace(output, "\r\n", "\n", -1)
+
+	if strings.HasPrefix(output, command) {
+		output = output[len(command):]
+		output = strings.TrimPrefix(output, "\n")
+	}
+
+	return output
+}
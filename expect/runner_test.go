@@ -0,0 +1,163 @@
+package expect
+
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+// fakeDevice pretends to be a network device, with a login, a "router#" prompt, an echo,
+// and a "--More--" pager.
+func fakeDevice(r io.Reader, w io.Writer) {
+	lines := bufio.NewReader(r)
+
+	readLine := func() string {
+		line, _ := lines.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	io.WriteString(w, "\r\nUser Access Verification\r\n\r\nUsername: ")
+	user := readLine()
+	io.WriteString(w, user+"\r\nPassword: ")
+	if "secret" != readLine() {
+		io.WriteString(w, "\r\n% Login invalid\r\n\r\nUsername: ")
+		return
+	}
+	io.WriteString(w, "\r\nLast failed login: Mon Oct 19 09:12:44 UTC 2026 from 10.0.0.9 on ssh:notty\r\n")
+	io.WriteString(w, "There was 1 failed login attempt since the last successful login.\r\nrouter#")
+
+	for {
+		command := readLine()
+		io.WriteString(w, command+"\r\n")
+
+		switch command {
+		case "show version":
+			io.WriteString(w, "Version 1.2.3\r\nUptime 42 days\r\n")
+		case "show running-config":
+			io.WriteString(w, "hostname router\r\n --More-- ")
+			lines.ReadByte()
+			io.WriteString(w, "\x08\x08\x08\x08\x08\x08\x08\x08\x08\x08          \x08\x08\x08\x08\x08\x08\x08\x08\x08\x08")
+			io.WriteString(w, "interface eth0\r\n --More-- ")
+			lines.ReadByte()
+			io.WriteString(w, "\x08\x08\x08\x08\x08\x08\x08\x08\x08\x08          \x08\x08\x08\x08\x08\x08\x08\x08\x08\x08")
+			io.WriteString(w, " ip address 10.0.0.1\r\nend\r\n")
+		case "exit":
+			return
+		}
+
+		io.WriteString(w, "router#")
+	}
+}
+
+
+func newFakeDeviceRunner() *Runner {
+	fromDevice, toClient := io.Pipe()
+	fromClient, toDevice := io.Pipe()
+
+	go func() {
+		fakeDevice(fromClient, toClient)
+		toClient.Close()
+	}()
+
+	session := NewSession(toDevice, fromDevice)
+
+	runner := NewRunner(session, regexp.MustCompile(`router#$`))
+	runner.Timeout = time.Second
+
+	return runner
+}
+
+
+func TestRunner(t *testing.T) {
+
+	runner := newFakeDeviceRunner()
+
+	if err := runner.Login("admin", "secret"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	tests := []struct{
+		Command  string
+		Expected string
+	}{
+		{
+			Command:  "show version",
+			Expected: "Version 1.2.3\nUptime 42 days\n",
+		},
+		{
+			Command:  "show running-config",
+			Expected: "hostname router\ninterface eth0\n ip address 10.0.0.1\nend\n",
+		},
+		{
+			Command:  "show nothing",
+			Expected: "",
+		},
+	}
+
+	for testNumber, test := range tests {
+		output, err := runner.Run(test.Command)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, output; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestRunnerLoginFailed(t *testing.T) {
+
+	runner := newFakeDeviceRunner()
+
+	if err := runner.Login("admin", "wrong"); ErrLoginFailed != err {
+		t.Errorf("Expected ErrLoginFailed, but actually got: (%T) %v", err, err)
+	}
+}
+
+
+func TestRunnerNoPrompt(t *testing.T) {
+
+	runner := newFakeDeviceRunner()
+	runner.Prompt = nil
+
+	if err := runner.Login("admin", "secret"); errNoPrompt != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", errNoPrompt, err, err)
+	}
+
+	if _, err := runner.Run("show version"); errNoPrompt != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", errNoPrompt, err, err)
+	}
+}
+
+
+func TestDefaultLoginFailure(t *testing.T) {
+
+	tests := []struct{
+		Text     string
+		Expected bool
+	}{
+		{Text: "\r\nLogin incorrect\r\n",                                        Expected: true},
+		{Text: "\r\n% Login invalid\r\n",                                        Expected: true},
+		{Text: "\r\nAccess denied.\r\n",                                         Expected: true},
+		{Text: "\r\nLast failed login: Mon Oct 19 from 10.0.0.9 on ssh:notty\r\n", Expected: false},
+		{Text: "\r\nThere was 1 failed login attempt since the last successful login.\r\n", Expected: false},
+		{Text: "\r\nInvalid input detected at '^' marker.\r\n",                 Expected: false},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, defaultLoginFailure.MatchString(test.Text); expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t, for %q.", testNumber, expected, actual, test.Text)
+			continue
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package expect
+
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/reiver/go-telnet"
+
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+
+var (
+	// ErrTimeout is returned by Expect and ExpectAny when nothing matched in time.
+	ErrTimeout = errors.New("Timed out waiting for a match.")
+
+	// ErrClosed is returned by Expect and ExpectAny when nothing matched, and the Session
+	// was closed.
+	ErrClosed = errors.New("Session closed.")
+)
+
+
+const readBufferSize = 1024
+
+const (
+	defaultMaxBuffer     = 64 * 1024
+	defaultMaxTranscript = 1024 * 1024
+)
+
+
+// A Session sends and receives data over a TELNET (or TELNETS) connection, and waits for
+// Patterns in what it receives.
+//
+// Once a Session is being used, everything should be read through it (and not directly
+// from the telnet.Reader it was created with). A Session reads in the background, until the
+// connection closes, or Close is called.
+type Session struct {
+	// Timeout is how long Expect and ExpectAny wait when they are called with a timeout
+	// of zero. If Timeout is also zero, then they wait forever (or until the connection closes).
+	Timeout time.Duration
+
+	// MaxBuffer is the most received (but not yet matched) data kept for matching; 64 KiB if
+	// zero. Once more than that has been received without a match, the oldest is dropped.
+	MaxBuffer int
+
+	// MaxTranscript is the most of what was received that is kept for Transcript; 1 MiB if
+	// zero. Once more than that has been received, the oldest is dropped.
+	MaxTranscript int
+
+	writer telnet.Writer
+	reader telnet.Reader
+
+	startOnce sync.Once
+	chunks    chan []byte
+	readErr   error // only valid once 'chunks' is closed.
+	closed    bool
+
+	stopOnce sync.Once
+	stop     chan struct{} // closed by Close.
+
+	buffer []byte // received, but not yet matched.
+
+	transcriptMutex sync.Mutex
+	transcript      bytes.Buffer
+}
+
+
+// A Match is what Expect and ExpectAny return when a Pattern matched.
+type Match struct {
+	Index      int      // which of the Patterns matched. (Always 0 for Expect.)
+	Before     string   // the text received before the match (and after the previous match).
+	Text       string   // the text that matched.
+	Submatches []string // for a Regexp Pattern, the text of the sub-matches; Submatches[0] is the same as Text.
+}
+
+
+// NewSession returns a new Session that sends with 'w', and receives with 'r'.
+//
+// For example:
+//
+//	session := expect.NewSession(conn, conn)
+func NewSession(w telnet.Writer, r telnet.Reader) *Session {
+	session := Session{
+		writer:w,
+		reader:r,
+		stop:make(chan struct{}),
+	}
+
+	return &session
+}
+
+
+func (session *Session) start() {
+	session.startOnce.Do(func(){
+		session.chunks = make(chan []byte, 16)
+
+		go func() {
+			defer close(session.chunks)
+
+			for {
+				p := make([]byte, readBufferSize)
+
+				n, err := session.reader.Read(p)
+				if 0 < n {
+					select {
+					case session.chunks <- p[:n]:
+					case <-session.stop:
+						session.readErr = ErrClosed
+						return
+					}
+				}
+
+				if nil != err {
+					select {
+					case <-session.stop:
+						session.readErr = ErrClosed
+					default:
+						session.readErr = err
+					}
+					return
+				}
+			}
+		}()
+	})
+}
+
+
+// Expect waits for 'pattern' to be received, and returns what matched.
+//
+// If 'timeout' is zero, then the Session's Timeout is used. If nothing matched in time, then
+// Expect returns ErrTimeout. If the connection closed first, then Expect returns the error
+// that reading got (such as io.EOF). In either case, what was received is kept, to be matched
+// by the next call to Expect or ExpectAny.
+func (session *Session) Expect(pattern Pattern, timeout time.Duration) (*Match, error) {
+	return session.ExpectAny(timeout, pattern)
+}
+
+
+// ExpectAny waits for any of 'patterns' to be received, and returns which one matched (as
+// Match.Index), and what matched.
+//
+// If more than one of the patterns could match, then the one that matches earliest in what
+// was received wins. (If they match at the same place, then the one that comes first in
+// 'patterns' wins.)
+//
+// Timeouts and errors work the same as with Expect.
+func (session *Session) ExpectAny(timeout time.Duration, patterns ...Pattern) (*Match, error) {
+
+	session.start()
+
+	if 0 == timeout {
+		timeout = session.Timeout
+	}
+
+	var expired <-chan time.Time
+	if 0 < timeout {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		if match := session.match(patterns); nil != match {
+			return match, nil
+		}
+
+		if session.closed {
+			return nil, session.readErr
+		}
+
+		select {
+		case chunk, ok := <-session.chunks:
+			if !ok {
+				session.closed = true
+				continue
+			}
+
+			session.buffer = append(session.buffer, chunk...)
+			if max := session.maxBuffer(); max < len(session.buffer) {
+				session.buffer = append([]byte(nil), session.buffer[len(session.buffer)-max:]...)
+			}
+
+			session.transcriptMutex.Lock()
+			session.transcript.Write(chunk)
+			if max := session.maxTranscript(); max < session.transcript.Len() {
+				kept := append([]byte(nil), session.transcript.Bytes()[session.transcript.Len()-max:]...)
+				session.transcript.Reset()
+				session.transcript.Write(kept)
+			}
+			session.transcriptMutex.Unlock()
+		case <-session.stop:
+			return nil, ErrClosed
+		case <-expired:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+
+// match looks for the earliest match of any of 'patterns' in what has been received, and
+// (if there is one) removes everything up to the end of it.
+func (session *Session) match(patterns []Pattern) *Match {
+
+	index := -1
+	var loc []int
+
+	for i, pattern := range patterns {
+		l := pattern.find(session.buffer)
+		if nil == l {
+			continue
+		}
+
+		if nil == loc || l[0] < loc[0] {
+			index = i
+			loc = l
+		}
+	}
+
+	if nil == loc {
+		return nil
+	}
+
+	match := Match{
+		Index:  index,
+		Before: string(session.buffer[:loc[0]]),
+		Text:   string(session.buffer[loc[0]:loc[1]]),
+	}
+
+	for i := 0; i+1 < len(loc); i += 2 {
+		var submatch string
+		if 0 <= loc[i] {
+			submatch = string(session.buffer[loc[i]:loc[i+1]])
+		}
+		match.Submatches = append(match.Submatches, submatch)
+	}
+
+	// (Copied, so that what was matched can be garbage collected.)
+	session.buffer = append([]byte(nil), session.buffer[loc[1]:]...)
+
+	return &match
+}
+
+
+func (session *Session) maxBuffer() int {
+	if session.MaxBuffer <= 0 {
+		return defaultMaxBuffer
+	}
+
+	return session.MaxBuffer
+}
+
+
+func (session *Session) maxTranscript() int {
+	if session.MaxTranscript <= 0 {
+		return defaultMaxTranscript
+	}
+
+	return session.MaxTranscript
+}
+
+
+// Send sends 's'.
+func (session *Session) Send(s string) error {
+	_, err := oi.LongWriteString(session.writer, s)
+	return err
+}
+
+
+// SendLine sends 's' followed by a TELNET end-of-line ("\r\n").
+func (session *Session) SendLine(s string) error {
+	return session.Send(s + "\r\n")
+}
+
+
+// Close stops the Session from reading in the background. It closes the telnet.Reader the
+// Session was created with, if it can be closed (such as a *telnet.Conn); since that is the
+// only way to interrupt a Read. After Close, Expect and ExpectAny only match what was already
+// received.
+func (session *Session) Close() error {
+	var err error
+
+	session.stopOnce.Do(func(){
+		close(session.stop)
+
+		if closer, ok := session.reader.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+
+	return err
+}
+
+
+// Transcript returns everything that has been received so far. (Or, the last MaxTranscript
+// bytes of it.)
+func (session *Session) Transcript() string {
+	session.transcriptMutex.Lock()
+	defer session.transcriptMutex.Unlock()
+
+	return session.transcript.String()
+}
@@ -0,0 +1,183 @@
+package expect
+
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+func TestSessionExpect(t *testing.T) {
+
+	reader, writer := io.Pipe()
+
+	var sent bytes.Buffer
+	session := NewSession(&sent, reader)
+
+	go func() {
+		// Sent in pieces, to make sure matches can span reads.
+		io.WriteString(writer, "Welcome!\r\nlog")
+		time.Sleep(5 * time.Millisecond)
+		io.WriteString(writer, "in: ")
+		time.Sleep(5 * time.Millisecond)
+		io.WriteString(writer, "Last login: today\r\nuptime: 42 days\r\n$ ")
+		writer.Close()
+	}()
+
+
+	match, err := session.Expect(String("login:"), time.Second)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "Welcome!\r\n", match.Before; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := "login:", match.Text; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if err := session.SendLine("joeblow"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "joeblow\r\n", sent.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+
+	match, err = session.Expect(Regexp(regexp.MustCompile(`uptime: (\d+) days`)), time.Second)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := " Last login: today\r\n", match.Before; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := 2, len(match.Submatches); expected != actual {
+		t.Fatalf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := "42", match.Submatches[1]; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+
+	match, err = session.ExpectAny(time.Second, String("Password:"), String("$ "), String("\r\n"))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := 2, match.Index; expected != actual {
+		t.Errorf("Expected the earliest match (%d), but actually got %d.", expected, actual)
+	}
+
+	match, err = session.ExpectAny(time.Second, String("Password:"), String("$ "))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := 1, match.Index; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+
+
+	if _, err := session.Expect(String("never"), time.Second); io.EOF != err {
+		t.Errorf("Expected io.EOF, but actually got: (%T) %v", err, err)
+	}
+
+	if expected, actual := "Welcome!\r\nlogin: Last login: today\r\nuptime: 42 days\r\n$ ", session.Transcript(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestSessionExpectTimeout(t *testing.T) {
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	session := NewSession(ioutil.Discard, reader)
+	session.Timeout = 20 * time.Millisecond
+
+	go io.WriteString(writer, "Password")
+
+	if _, err := session.Expect(String("Password:"), 0); ErrTimeout != err {
+		t.Fatalf("Expected ErrTimeout, but actually got: (%T) %v", err, err)
+	}
+
+	// What was received is kept, so that it can still be matched.
+	go io.WriteString(writer, ":")
+
+	match, err := session.Expect(String("Password:"), time.Second)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "Password:", match.Text; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestSessionClose(t *testing.T) {
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	session := NewSession(ioutil.Discard, reader)
+
+	go io.WriteString(writer, "Password")
+
+	if _, err := session.Expect(String("Password:"), 20 * time.Millisecond); ErrTimeout != err {
+		t.Fatalf("Expected ErrTimeout, but actually got: (%T) %v", err, err)
+	}
+
+	if err := session.Close(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// The background reader closed the pipe; so writing to it fails.
+	if _, err := io.WriteString(writer, ":"); io.ErrClosedPipe != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", io.ErrClosedPipe, err, err)
+	}
+
+	// What was already received can still be matched.
+	match, err := session.Expect(String("Pass"), time.Second)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "Pass", match.Text; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if _, err := session.Expect(String("Password:"), time.Second); ErrClosed != err {
+		t.Errorf("Expected ErrClosed, but actually got: (%T) %v", err, err)
+	}
+}
+
+
+func TestSessionMaxBuffer(t *testing.T) {
+
+	reader, writer := io.Pipe()
+
+	session := NewSession(ioutil.Discard, reader)
+	session.MaxBuffer = 10
+	session.MaxTranscript = 12
+
+	go func() {
+		io.WriteString(writer, strings.Repeat("x", 100))
+		io.WriteString(writer, "end")
+		writer.Close()
+	}()
+
+	match, err := session.Expect(String("end"), time.Second)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "xxxxxxx", match.Before; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := "xxxxxxxxxend", session.Transcript(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,182 @@
+package telnet
+
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+
+const (
+	asciiXON  = 0x11 // ^Q
+	asciiXOFF = 0x13 // ^S
+)
+
+
+// An internalFlowControl implements the client side of TOGGLE-FLOW-CONTROL (RFC 1372); i.e.,
+// local flow control.
+//
+// When we have agreed to do TOGGLE-FLOW-CONTROL, and flow control is ON, an XOFF (^S) typed by
+// the user (i.e., written to the connection) is not sent, but instead stops the output (i.e.,
+// what Read returns) until an XON (^Q) is typed. Or, if the server asked for RESTART-ANY, until
+// any character is typed. When flow control is OFF, XONs and XOFFs are sent like any other data.
+//
+// The server turns flow control ON and OFF (and picks how output is restarted) with
+// subnegotiations. (See Conn.SetFlowControl.)
+//
+// TOGGLE-FLOW-CONTROL is only agreed to if it was asked for. (See Conn.AcceptFlowControl.) And,
+// while we are sending with TRANSMIT-BINARY, XONs and XOFFs are just data; so they are sent.
+type internalFlowControl struct {
+	mutex   sync.Mutex
+	changed chan struct{} // closed (and replaced) whenever any of the following changes.
+
+	enabled    bool // whether TOGGLE-FLOW-CONTROL was agreed to.
+	binary     bool // whether we are sending with TRANSMIT-BINARY.
+	on         bool // LFLOW ON or LFLOW OFF.
+	restartAny bool // LFLOW RESTART-ANY or LFLOW RESTART-XON.
+	stopped    bool // whether an XOFF was typed.
+}
+
+
+func newFlowControl() *internalFlowControl {
+	flow := internalFlowControl{
+		changed:make(chan struct{}),
+		on:true,
+	}
+
+	return &flow
+}
+
+
+// notify wakes up anything waiting in wait. It must be called with the mutex locked.
+func (flow *internalFlowControl) notify() {
+	close(flow.changed)
+	flow.changed = make(chan struct{})
+}
+
+
+// enable turns flow control on or off, as a result of option negotiation.
+func (flow *internalFlowControl) enable(enabled bool) {
+	flow.mutex.Lock()
+	defer flow.mutex.Unlock()
+
+	flow.enabled = enabled
+	if !enabled {
+		flow.stopped = false
+	}
+	flow.notify()
+}
+
+
+// setBinary records whether we are sending with TRANSMIT-BINARY; in which case, flow control is
+// not done (and any stopped output is restarted).
+func (flow *internalFlowControl) setBinary(binary bool) {
+	flow.mutex.Lock()
+	defer flow.mutex.Unlock()
+
+	flow.binary = binary
+	if binary {
+		flow.stopped = false
+	}
+	flow.notify()
+}
+
+
+// command deals with the LFLOW subnegotiation commands (from the server): OFF, ON, RESTART-ANY,
+// and RESTART-XON.
+func (flow *internalFlowControl) command(c byte) {
+	flow.mutex.Lock()
+	defer flow.mutex.Unlock()
+
+	switch c {
+	case lflowOFF:
+		flow.on = false
+		flow.stopped = false
+	case lflowON:
+		flow.on = true
+	case lflowRESTARTANY:
+		flow.restartAny = true
+	case lflowRESTARTXON:
+		flow.restartAny = false
+	}
+	flow.notify()
+}
+
+
+// active reports whether XONs and XOFFs are currently being acted on.
+func (flow *internalFlowControl) active() bool {
+	flow.mutex.Lock()
+	defer flow.mutex.Unlock()
+
+	return flow.enabled && flow.on && !flow.binary
+}
+
+
+// observe looks at a data byte typed by the user (i.e., about to be sent), and returns whether
+// it was consumed as an XON or XOFF. (If so, it should not be sent.)
+func (flow *internalFlowControl) observe(b byte) bool {
+	flow.mutex.Lock()
+	defer flow.mutex.Unlock()
+
+	if !flow.enabled || !flow.on || flow.binary {
+		return false
+	}
+
+	switch {
+	case asciiXOFF == b:
+		flow.stopped = true
+		return true
+	case asciiXON == b:
+		if flow.stopped {
+			flow.stopped = false
+			flow.notify()
+		}
+		return true
+	case flow.stopped && flow.restartAny:
+		flow.stopped = false
+		flow.notify()
+	}
+
+	return false
+}
+
+
+// release restarts any stopped output. It is used when the connection is going away,
+// so that a blocked reader does not stay blocked forever.
+func (flow *internalFlowControl) release() {
+	flow.enable(false)
+}
+
+
+// wait blocks while output is stopped. If 'deadline' is not zero, then wait gives up (and
+// returns an error) once it passes.
+func (flow *internalFlowControl) wait(deadline time.Time) error {
+	for {
+		flow.mutex.Lock()
+		stopped := flow.enabled && flow.on && !flow.binary && flow.stopped
+		changed := flow.changed
+		flow.mutex.Unlock()
+
+		if !stopped {
+			return nil
+		}
+
+		if deadline.IsZero() {
+			<-changed
+			continue
+		}
+
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+
+		timer := time.NewTimer(timeout)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,422 @@
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// defaultForwardDialTimeout is how long ForwardHandler waits to connect to a backend, if its
+// DialTimeout is zero.
+const defaultForwardDialTimeout = 10 * time.Second
+
+// forwardMenuTries is how many times ForwardHandler asks the user to pick a backend, before
+// it gives up.
+const forwardMenuTries = 3
+
+// maxForwardMenuLine is the longest answer to the menu that ForwardHandler reads.
+const maxForwardMenuLine = 256
+
+
+var (
+	errForwardNoBackends = errors.New("ForwardHandler has no Backends.")
+	errForwardNoPick     = errors.New("No backend was picked.")
+)
+
+
+// ForwardHandler is a Handler that connects each TELNET client to a backend over plain TCP, and
+// copies the data both ways; i.e., a TELNET-to-raw-TCP gateway. (Such as for the consoles
+// behind a console server.)
+//
+// For example:
+//
+//	handler := &telnet.ForwardHandler{
+//		Backends: []telnet.ForwardBackend{
+//			{Name:"router1", Addr:"10.0.0.7:7001"},
+//			{Name:"router2", Addr:"10.0.0.7:7002"},
+//		},
+//	}
+//
+//	err := telnet.ListenAndServe(":5555", handler)
+//
+// If there is just one backend, then each client is connected to it. If there is more than one,
+// then the client is shown a numbered menu of them, and is connected to the one they pick. (The
+// number, or the name, can be typed.)
+//
+// The backend gets just the data: the TELNET commands are taken out, and CR NUL becomes CR.
+// (CR LF is passed through as is.) What the backend sends is escaped as TELNET data. Once
+// connected, the server does the ECHO and SUPPRESS-GO-AHEAD options; so the backend does the
+// echoing, and what the user types is sent a character at a time.
+//
+// When either side shuts down its writing side (i.e., a half-close) that is passed on to the
+// other side; ServeTELNET returns once both directions are done (or either side goes away).
+type ForwardHandler struct {
+	Backends []ForwardBackend
+
+	DialTimeout time.Duration // how long to wait to connect to a backend; 10 seconds if zero.
+
+	// Done, if not nil, is called at the end of each connection, with the number of bytes sent
+	// each way.
+	Done func(Context, ForwardStats)
+}
+
+
+// A ForwardBackend is a host a ForwardHandler can connect clients to.
+type ForwardBackend struct {
+	Name string // what the backend is called in the menu; Addr is used if empty.
+	Addr string // the TCP address; such as "10.0.0.7:7001".
+}
+
+
+// ForwardStats are the numbers for a connection a ForwardHandler served.
+type ForwardStats struct {
+	Backend ForwardBackend
+
+	ToBackend   int64 // bytes sent from the client to the backend.
+	FromBackend int64 // bytes sent from the backend to the client.
+
+	Duration time.Duration // how long the client was connected to the backend.
+}
+
+
+// ServeTELNET connects the client to a backend.
+func (handler *ForwardHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	stats, err := handler.serve(ctx, w, r)
+
+	if "" != stats.Backend.Addr {
+		logger.Debugf("Forwarded to %s: %d bytes to it, %d bytes from it, over %v.", stats.Backend.Addr, stats.ToBackend, stats.FromBackend, stats.Duration)
+	}
+	if nil != err {
+		logger.Errorf("Problem forwarding: %v", err)
+	}
+
+	if nil != handler.Done && "" != stats.Backend.Addr {
+		handler.Done(ctx, stats)
+	}
+}
+
+
+func (handler *ForwardHandler) serve(ctx Context, w Writer, r Reader) (ForwardStats, error) {
+
+	var stats ForwardStats
+
+	backend, typed, typedCR, err := handler.pick(w, r)
+	if nil != err {
+		return stats, err
+	}
+	stats.Backend = backend
+
+	timeout := handler.DialTimeout
+	if 0 >= timeout {
+		timeout = defaultForwardDialTimeout
+	}
+
+	remote, err := net.DialTimeout("tcp", backend.Addr, timeout)
+	if nil != err {
+		oi.LongWriteString(w, "Could not connect to "+backend.name()+".\r\n")
+		return stats, err
+	}
+	defer remote.Close()
+
+	conn, _ := r.(*Conn)
+	if nil != conn {
+		if err := forwardNegotiate(conn); nil != err {
+			return stats, err
+		}
+	}
+
+	started := time.Now()
+
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	var toBackendErr, fromBackendErr error
+
+	// Client to backend.
+	go func() {
+		defer wg.Done()
+
+		// If the answer to the menu ended with a CR, then a NUL after it is part of that.
+		lastCR := typedCR
+
+		write := func(p []byte) error {
+			if nil == conn || !conn.negotiator.remoteEnabled(optionBinary) {
+				p, lastCR = translateForwardInput(p, lastCR)
+			}
+
+			n, err := oi.LongWrite(remote, p)
+			stats.ToBackend += n
+			return err
+		}
+
+		if 0 < len(typed) {
+			if err := write(typed); nil != err {
+				toBackendErr = err
+				return
+			}
+		}
+
+		var buffer [1024]byte
+		for {
+			n, err := r.Read(buffer[:])
+			if 0 < n {
+				if err := write(buffer[:n]); nil != err {
+					toBackendErr = err
+					remote.Close()
+					return
+				}
+			}
+
+			if io.EOF == err {
+				// A half-close from the client; so half-close the backend too. (If the backend
+				// cannot be, then this is the end.)
+				if err := closeWrite(remote); nil != err {
+					remote.Close()
+				}
+				return
+			}
+			if nil != err {
+				toBackendErr = err
+				remote.Close()
+				return
+			}
+		}
+	}()
+
+	// Backend to client.
+	go func() {
+		defer wg.Done()
+
+		var buffer [1024]byte
+		for {
+			n, err := remote.Read(buffer[:])
+			if 0 < n {
+				stats.FromBackend += int64(n)
+				if _, err := oi.LongWrite(w, buffer[:n]); nil != err {
+					fromBackendErr = err
+					remote.Close()
+					return
+				}
+			}
+
+			if io.EOF == err {
+				// A half-close from the backend; so half-close the client too. (If the client
+				// cannot be, then this is the end.)
+				if err := closeWrite(w); nil != err {
+					forwardHangUp(w, remote)
+				}
+				return
+			}
+			if nil != err {
+				// The backend went away (or was closed, once the client went away).
+				forwardHangUp(w, remote)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	stats.Duration = time.Since(started)
+
+	if nil != fromBackendErr {
+		return stats, fromBackendErr
+	}
+	if errors.Is(toBackendErr, net.ErrClosed) {
+		// The connection to the backend was closed; i.e., this is how it ended.
+		toBackendErr = nil
+	}
+
+	return stats, toBackendErr
+}
+
+
+// forwardHangUp ends the connection to the client (if 'w' can be closed), and to the backend.
+// (Which makes the other direction stop.)
+func forwardHangUp(w Writer, remote net.Conn) {
+	remote.Close()
+	if closer, ok := w.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+
+// pick returns the backend to connect the client to; showing the menu of backends if there is
+// more than one. It also returns anything the client typed after its answer (which should be
+// sent to the backend), and whether the answer ended with a CR. (So that a NUL right after it is
+// not sent to the backend.)
+func (handler *ForwardHandler) pick(w Writer, r Reader) (ForwardBackend, []byte, bool, error) {
+
+	switch len(handler.Backends) {
+	case 0:
+		return ForwardBackend{}, nil, false, errForwardNoBackends
+	case 1:
+		return handler.Backends[0], nil, false, nil
+	}
+
+	var menu bytes.Buffer
+	for i, backend := range handler.Backends {
+		fmt.Fprintf(&menu, "%d) %s\r\n", i+1, backend.name())
+	}
+
+	reader := forwardLineReader{r:r}
+
+	for try := 0; try < forwardMenuTries; try++ {
+		if _, err := oi.LongWrite(w, menu.Bytes()); nil != err {
+			return ForwardBackend{}, nil, false, err
+		}
+		if _, err := oi.LongWriteString(w, "Connect to: "); nil != err {
+			return ForwardBackend{}, nil, false, err
+		}
+
+		line, err := reader.readLine()
+		if nil != err {
+			return ForwardBackend{}, nil, false, err
+		}
+
+		if backend, ok := handler.lookup(line); ok {
+			return backend, reader.rest, reader.lastCR, nil
+		}
+
+		if _, err := oi.LongWriteString(w, "No such host.\r\n"); nil != err {
+			return ForwardBackend{}, nil, false, err
+		}
+	}
+
+	return ForwardBackend{}, nil, false, errForwardNoPick
+}
+
+
+// lookup returns the backend with the number (starting from 1), or the name, 'answer'.
+func (handler *ForwardHandler) lookup(answer string) (ForwardBackend, bool) {
+	answer = strings.TrimSpace(answer)
+	if "" == answer {
+		return ForwardBackend{}, false
+	}
+
+	if number, err := strconv.Atoi(answer); nil == err {
+		if 1 <= number && number <= len(handler.Backends) {
+			return handler.Backends[number-1], true
+		}
+		return ForwardBackend{}, false
+	}
+
+	for _, backend := range handler.Backends {
+		if strings.EqualFold(answer, backend.name()) {
+			return backend, true
+		}
+	}
+
+	return ForwardBackend{}, false
+}
+
+
+func (backend ForwardBackend) name() string {
+	if "" == backend.Name {
+		return backend.Addr
+	}
+
+	return backend.Name
+}
+
+
+// forwardNegotiate offers the options a ForwardHandler wants once the client is connected to a
+// backend: the server does ECHO and SUPPRESS-GO-AHEAD (so the backend echoes, and the client
+// sends a character at a time).
+func forwardNegotiate(conn *Conn) error {
+	for _, option := range []byte{optionEcho, optionSuppressGoAhead} {
+		if err := conn.negotiator.offerLocal(option); nil != err {
+			return err
+		}
+	}
+
+	return conn.negotiator.offerRemote(optionSuppressGoAhead)
+}
+
+
+// translateForwardInput turns each CR NUL in 'p' (from a client that is not in binary mode)
+// into just CR. (CR LF is left alone.)
+//
+// 'lastCR' is whether the data before 'p' ended with a CR; translateForwardInput returns what 'p'
+// became, and whether it ended with a CR.
+func translateForwardInput(p []byte, lastCR bool) ([]byte, bool) {
+	translated := p[:0]
+
+	for _, b := range p {
+		if lastCR && 0 == b {
+			lastCR = false
+			continue
+		}
+
+		translated = append(translated, b)
+		lastCR = '\r' == b
+	}
+
+	return translated, lastCR
+}
+
+
+// A forwardLineReader reads the lines typed at the ForwardHandler menu. Whatever was read after
+// a line is kept in 'rest'.
+type forwardLineReader struct {
+	r      Reader
+	rest   []byte
+	lastCR bool // whether the last line ended with a CR; so that an LF (or NUL) after it is skipped.
+}
+
+
+// readLine returns the next line, without its line ending. (A line ends with CR LF, CR NUL, CR,
+// or LF.)
+func (reader *forwardLineReader) readLine() (string, error) {
+	var line []byte
+
+	for {
+		if reader.lastCR && 0 < len(reader.rest) {
+			if '\n' == reader.rest[0] || 0 == reader.rest[0] {
+				reader.rest = reader.rest[1:]
+			}
+			reader.lastCR = false
+		}
+
+		if i := bytes.IndexAny(reader.rest, "\r\n"); 0 <= i {
+			line = append(line, reader.rest[:i]...)
+			reader.lastCR = '\r' == reader.rest[i]
+			reader.rest = reader.rest[i+1:]
+
+			if reader.lastCR && 0 < len(reader.rest) && ('\n' == reader.rest[0] || 0 == reader.rest[0]) {
+				reader.rest = reader.rest[1:]
+				reader.lastCR = false
+			}
+
+			return string(line), nil
+		}
+
+		line = append(line, reader.rest...)
+		reader.rest = nil
+		if maxForwardMenuLine < len(line) {
+			line = line[:maxForwardMenuLine]
+		}
+
+		var buffer [256]byte
+		n, err := reader.r.Read(buffer[:])
+		reader.rest = append(reader.rest, buffer[:n]...)
+		if nil != err && 0 == n {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package telnet
+
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+// forwardTestBackend starts a raw TCP server, that calls 'serve' for each connection, and
+// returns its address.
+func forwardTestBackend(t *testing.T, serve func(net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+
+// forwardTestServe serves 'handler' over TELNET, and returns a client connected to it.
+func forwardTestServe(t *testing.T, handler Handler) *Conn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go Serve(listener, handler)
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+}
+
+
+func TestForwardHandler(t *testing.T) {
+
+	// The backend replies with what it got, once the client half-closes.
+	addr := forwardTestBackend(t, func(conn net.Conn) {
+		received, _ := ioutil.ReadAll(conn)
+		conn.Write([]byte("got: "))
+		conn.Write(received)
+	})
+
+	done := make(chan ForwardStats, 1)
+	handler := &ForwardHandler{
+		Backends: []ForwardBackend{{Addr:addr}},
+		Done: func(ctx Context, stats ForwardStats) {
+			done <- stats
+		},
+	}
+
+	conn := forwardTestServe(t, handler)
+
+	if _, err := conn.Write([]byte("apple\xff\r\x00banana\r\n")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := conn.CloseWrite(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p, err := ioutil.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "got: apple\xff\rbanana\r\n", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	select {
+	case stats := <-done:
+		if expected, actual := int64(len("apple\xff\rbanana\r\n")), stats.ToBackend; expected != actual {
+			t.Errorf("Expected %d bytes to the backend, but actually got %d.", expected, actual)
+		}
+		if expected, actual := int64(len("got: apple\xff\rbanana\r\n")), stats.FromBackend; expected != actual {
+			t.Errorf("Expected %d bytes from the backend, but actually got %d.", expected, actual)
+		}
+		if expected, actual := addr, stats.Backend.Addr; expected != actual {
+			t.Errorf("Expected backend %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Done was not called.")
+	}
+}
+
+
+func TestForwardHandlerMenu(t *testing.T) {
+
+	tests := []struct{
+		Answers  string
+		Expected string
+	}{
+		{
+			Answers:  "2\r\n",
+			Expected: "this is beta",
+		},
+		{
+			Answers:  "ALPHA\r\x00",
+			Expected: "this is alpha",
+		},
+		{
+			Answers:  "3\r\ngamma\r\nbeta\r\n",
+			Expected: "this is beta",
+		},
+		{
+			Answers:  "0\r\n\r\nx\r\n",
+			Expected: "",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		backend := func(name string) string {
+			return forwardTestBackend(t, func(conn net.Conn) {
+				io.WriteString(conn, "this is "+name)
+			})
+		}
+
+		handler := &ForwardHandler{
+			Backends: []ForwardBackend{
+				{Name:"alpha", Addr:backend("alpha")},
+				{Name:"beta",  Addr:backend("beta")},
+			},
+		}
+
+		conn := forwardTestServe(t, handler)
+
+		if _, err := conn.Write([]byte(test.Answers)); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		p, err := ioutil.ReadAll(conn)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if !bytes.HasPrefix(p, []byte("1) alpha\r\n2) beta\r\nConnect to: ")) {
+			t.Errorf("For test #%d, expected the menu, but actually got %q.", testNumber, p)
+			continue
+		}
+
+		menus := strings.Count(string(p), "Connect to: ")
+		output := p[strings.LastIndex(string(p), "Connect to: ")+len("Connect to: "):]
+		if "" == test.Expected {
+			if expected, actual := forwardMenuTries, menus; expected != actual {
+				t.Errorf("For test #%d, expected the menu %d times, but actually got it %d times.", testNumber, expected, actual)
+			}
+			continue
+		}
+
+		if expected, actual := test.Expected, string(output); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestForwardHandlerMenuLineEnding(t *testing.T) {
+
+	// The backend replies with what it got, once the client half-closes.
+	backend := func(name string) string {
+		return forwardTestBackend(t, func(conn net.Conn) {
+			received, _ := ioutil.ReadAll(conn)
+			io.WriteString(conn, name+" got: ")
+			conn.Write(received)
+		})
+	}
+
+	handler := &ForwardHandler{
+		Backends: []ForwardBackend{
+			{Name:"alpha", Addr:backend("alpha")},
+			{Name:"beta",  Addr:backend("beta")},
+		},
+	}
+
+	conn := forwardTestServe(t, handler)
+
+	// The CR NUL at the end of the answer is split up; the NUL comes once already connected.
+	if _, err := conn.Write([]byte("2\r")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := conn.Write([]byte("\x00apple\r\n")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := conn.CloseWrite(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p, err := ioutil.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	output := p[strings.LastIndex(string(p), "Connect to: ")+len("Connect to: "):]
+	if expected, actual := "beta got: apple\r\n", string(output); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
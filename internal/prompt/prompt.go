@@ -0,0 +1,27 @@
+/*
+Package prompt has the regular expressions for the common login prompts and login failure
+messages, which are shared by the telnet package (for automatic login) and the expect package
+(for Runner.Login).
+*/
+package prompt
+
+
+import (
+	"regexp"
+)
+
+
+var (
+	// Login matches a login prompt (such as "login: " or "Username:") at the end of what was received.
+	Login = regexp.MustCompile(`(?i)(login|username|user name)\s*:\s*$`)
+
+	// Password matches a password prompt (such as "Password: ") at the end of what was received.
+	Password = regexp.MustCompile(`(?i)password\s*:\s*$`)
+
+	// LoginFailure matches a line that is (only) a login failure message; such as "Login incorrect".
+	//
+	// A banner or message of the day that just mentions (for example) "invalid" does not match.
+	// But even a whole line could be part of a banner; so a match only means the login failed
+	// if the login (or password) prompt comes next.
+	LoginFailure = regexp.MustCompile(`(?im)^\W*(login incorrect|login invalid|login failed|authentication failed|access denied|permission denied)\W*$`)
+)
@@ -0,0 +1,671 @@
+package telnet
+
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+
+// maxLineEditorHistory is the most lines of history that are kept.
+const maxLineEditorHistory = 1000
+
+
+// A lineEditorAction is what a key typed into an internalLineEditor resulted in.
+type lineEditorAction int
+
+const (
+	lineEditorNone      lineEditorAction = iota
+	lineEditorLine                       // a line was entered.
+	lineEditorInterrupt                  // Ctrl-C was typed.
+	lineEditorEOF                        // Ctrl-D was typed on an empty line.
+	lineEditorSuspend                    // Ctrl-Z was typed.
+)
+
+
+// An internalLineEditor is a (readline-like) local line editor, used by StandardCaller in
+// line mode when line editing is turned on.
+//
+// It is fed what is typed (with the terminal in raw mode) a byte at a time, draws the line being
+// edited on 'out', and returns each line once Enter is typed. It supports:
+//
+// • moving the cursor with the arrow keys, Home, End, Ctrl-A, Ctrl-E, Ctrl-B, Ctrl-F, Alt-B and Alt-F;
+//
+// • deleting with Backspace, Delete, Ctrl-D, Ctrl-W, Alt-D, Ctrl-U and Ctrl-K;
+//
+// • going through the history with the up and down arrow keys, Ctrl-P and Ctrl-N;
+//
+// • searching the history with Ctrl-R;
+//
+// • completion with Tab, if there is a 'complete' func.
+//
+// It only knows what it itself draws. (The line being edited is drawn after whatever is already
+// on the screen, such as the server's prompt.) So that the prompt can be drawn again (after the
+// screen is cleared, for example), it asks 'prompt' for it.
+type internalLineEditor struct {
+	out       io.Writer
+	prompt    func() string
+	complete  func(line string) []string
+	onHistory func(line string) // called with each line added to the history.
+
+	line   []rune
+	pos    int // where the cursor is in 'line'.
+	cursor int // where the cursor is drawn; in columns from the start of what the editor drew.
+
+	history      []string
+	historyIndex int    // the history entry being shown; len(history) for the line being typed.
+	edited       []rune // the line being typed, while going through the history.
+
+	searching   bool
+	search      []rune
+	searchIndex int // the history entry the search found; -1 if none.
+
+	pending []byte // a partial escape sequence, or a partial UTF-8 encoded character.
+	lastKey byte
+}
+
+
+func newLineEditor(out io.Writer, history []string) *internalLineEditor {
+	editor := internalLineEditor{
+		out:out,
+		history:history,
+		historyIndex:len(history),
+	}
+
+	return &editor
+}
+
+
+// feed gives the line editor the next byte typed. If that finished a line, it returns the line,
+// and lineEditorLine.
+func (editor *internalLineEditor) feed(b byte) (string, lineEditorAction) {
+
+	editor.pending = append(editor.pending, b)
+	p := editor.pending
+
+	switch {
+	case 0x1B == p[0]:
+		if !escapeSequenceComplete(p) {
+			return "", lineEditorNone
+		}
+		sequence := string(p)
+		editor.pending = editor.pending[:0]
+		editor.escapeSequence(sequence)
+		return "", lineEditorNone
+	case utf8.RuneSelf <= p[0]:
+		if !utf8.FullRune(p) {
+			return "", lineEditorNone
+		}
+		r, _ := utf8.DecodeRune(p)
+		editor.pending = editor.pending[:0]
+		editor.insert(r)
+		return "", lineEditorNone
+	}
+
+	editor.pending = editor.pending[:0]
+
+	lastKey := editor.lastKey
+	editor.lastKey = b
+
+	// In raw mode, Enter gives a CR; but a pasted CR LF should not give an extra empty line.
+	if '\n' == b && '\r' == lastKey {
+		return "", lineEditorNone
+	}
+
+	if editor.searching {
+		if done := editor.searchKey(b); done {
+			return "", lineEditorNone
+		}
+	}
+
+	switch b {
+	case '\r', '\n':
+		return editor.enter(), lineEditorLine
+	case 0x01: // Ctrl-A
+		editor.moveTo(0)
+	case 0x02: // Ctrl-B
+		editor.moveTo(editor.pos - 1)
+	case 0x03: // Ctrl-C
+		editor.moveTo(len(editor.line))
+		io.WriteString(editor.out, "^C\r\n")
+		editor.reset()
+		return "", lineEditorInterrupt
+	case 0x04: // Ctrl-D
+		if 0 == len(editor.line) {
+			return "", lineEditorEOF
+		}
+		editor.deleteRange(editor.pos, editor.pos + 1)
+	case 0x05: // Ctrl-E
+		editor.moveTo(len(editor.line))
+	case 0x06: // Ctrl-F
+		editor.moveTo(editor.pos + 1)
+	case 0x08, 0x7F: // Ctrl-H, Backspace
+		editor.deleteRange(editor.pos - 1, editor.pos)
+	case 0x09: // Tab
+		editor.completion(0x09 == lastKey)
+	case 0x0B: // Ctrl-K
+		editor.deleteRange(editor.pos, len(editor.line))
+	case 0x0C: // Ctrl-L
+		io.WriteString(editor.out, "\x1b[H\x1b[2J")
+		editor.redraw()
+	case 0x0E: // Ctrl-N
+		editor.historyMove(1)
+	case 0x10: // Ctrl-P
+		editor.historyMove(-1)
+	case 0x12: // Ctrl-R
+		editor.startSearch()
+	case 0x15: // Ctrl-U
+		editor.deleteRange(0, editor.pos)
+	case 0x17: // Ctrl-W
+		editor.deleteRange(editor.wordBackward(unicode.IsSpace), editor.pos)
+	case 0x1A: // Ctrl-Z
+		return "", lineEditorSuspend
+	default:
+		if 0x20 <= b {
+			editor.insert(rune(b))
+		}
+	}
+
+	return "", lineEditorNone
+}
+
+
+// escapeSequenceComplete reports whether 'p' (which starts with an ESC) is a complete escape
+// sequence; i.e., either ESC followed by a single character (such as for Alt-B), or a CSI
+// (ESC [) or SS3 (ESC O) sequence up to its final byte.
+func escapeSequenceComplete(p []byte) bool {
+	if len(p) < 2 {
+		return false
+	}
+
+	switch p[1] {
+	case '[', 'O':
+		if len(p) < 3 {
+			return false
+		}
+		last := p[len(p)-1]
+		return 0x40 <= last && last <= 0x7E
+	default:
+		return true
+	}
+}
+
+
+func (editor *internalLineEditor) escapeSequence(sequence string) {
+
+	if editor.searching {
+		editor.acceptSearch()
+	}
+
+	switch sequence {
+	case "\x1b[A", "\x1bOA": // Up
+		editor.historyMove(-1)
+	case "\x1b[B", "\x1bOB": // Down
+		editor.historyMove(1)
+	case "\x1b[C", "\x1bOC": // Right
+		editor.moveTo(editor.pos + 1)
+	case "\x1b[D", "\x1bOD": // Left
+		editor.moveTo(editor.pos - 1)
+	case "\x1b[H", "\x1bOH", "\x1b[1~", "\x1b[7~": // Home
+		editor.moveTo(0)
+	case "\x1b[F", "\x1bOF", "\x1b[4~", "\x1b[8~": // End
+		editor.moveTo(len(editor.line))
+	case "\x1b[3~": // Delete
+		editor.deleteRange(editor.pos, editor.pos + 1)
+	case "\x1bb", "\x1b[1;5D": // Alt-B, Ctrl-Left
+		editor.moveTo(editor.wordBackward(isNotWordRune))
+	case "\x1bf", "\x1b[1;5C": // Alt-F, Ctrl-Right
+		editor.moveTo(editor.wordForward())
+	case "\x1bd": // Alt-D
+		editor.deleteRange(editor.pos, editor.wordForward())
+	case "\x1b\x7f": // Alt-Backspace
+		editor.deleteRange(editor.wordBackward(isNotWordRune), editor.pos)
+	}
+}
+
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+
+// wordBackward returns where the word before the cursor starts; where words are separated by
+// runes for which 'separator' returns true.
+func (editor *internalLineEditor) wordBackward(separator func(rune) bool) int {
+	i := editor.pos
+	for 0 < i && separator(editor.line[i-1]) {
+		i--
+	}
+	for 0 < i && !separator(editor.line[i-1]) {
+		i--
+	}
+
+	return i
+}
+
+
+// wordForward returns where the word after the cursor ends.
+func (editor *internalLineEditor) wordForward() int {
+	i := editor.pos
+	for i < len(editor.line) && isNotWordRune(editor.line[i]) {
+		i++
+	}
+	for i < len(editor.line) && !isNotWordRune(editor.line[i]) {
+		i++
+	}
+
+	return i
+}
+
+
+func (editor *internalLineEditor) insert(r rune) {
+	if editor.searching {
+		editor.search = append(editor.search, r)
+		editor.find(editor.searchIndex)
+		editor.render()
+		return
+	}
+
+	editor.line = append(editor.line, 0)
+	copy(editor.line[editor.pos+1:], editor.line[editor.pos:])
+	editor.line[editor.pos] = r
+	editor.pos++
+
+	editor.render()
+}
+
+
+func (editor *internalLineEditor) deleteRange(from int, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if len(editor.line) < to {
+		to = len(editor.line)
+	}
+	if to <= from {
+		return
+	}
+
+	editor.line = append(editor.line[:from], editor.line[to:]...)
+	editor.pos = from
+
+	editor.render()
+}
+
+
+func (editor *internalLineEditor) moveTo(pos int) {
+	if pos < 0 || len(editor.line) < pos {
+		return
+	}
+
+	editor.pos = pos
+
+	editor.render()
+}
+
+
+// enter ends the line; and returns it.
+func (editor *internalLineEditor) enter() string {
+	editor.moveTo(len(editor.line))
+	io.WriteString(editor.out, "\r\n")
+
+	line := string(editor.line)
+
+	editor.addHistory(line)
+	editor.reset()
+
+	return line
+}
+
+
+func (editor *internalLineEditor) reset() {
+	editor.line = nil
+	editor.pos = 0
+	editor.cursor = 0
+	editor.historyIndex = len(editor.history)
+	editor.edited = nil
+	editor.searching = false
+}
+
+
+func (editor *internalLineEditor) setLine(line []rune) {
+	editor.line = append([]rune(nil), line...)
+	editor.pos = len(editor.line)
+}
+
+
+// addHistory adds 'line' to the history (unless it is blank, or the same as the previous line).
+func (editor *internalLineEditor) addHistory(line string) {
+	if "" == strings.TrimSpace(line) {
+		return
+	}
+	if 0 < len(editor.history) && line == editor.history[len(editor.history)-1] {
+		return
+	}
+
+	editor.history = append(editor.history, line)
+	if maxLineEditorHistory < len(editor.history) {
+		editor.history = editor.history[len(editor.history)-maxLineEditorHistory:]
+	}
+
+	if nil != editor.onHistory {
+		editor.onHistory(line)
+	}
+}
+
+
+// historyMove shows the previous (if 'delta' is -1) or next (if 'delta' is 1) history entry.
+func (editor *internalLineEditor) historyMove(delta int) {
+	index := editor.historyIndex + delta
+	if index < 0 || len(editor.history) < index {
+		return
+	}
+
+	if len(editor.history) == editor.historyIndex {
+		editor.edited = append([]rune(nil), editor.line...)
+	}
+
+	editor.historyIndex = index
+	if len(editor.history) == index {
+		editor.setLine(editor.edited)
+	} else {
+		editor.setLine([]rune(editor.history[index]))
+	}
+
+	editor.render()
+}
+
+
+func (editor *internalLineEditor) startSearch() {
+	if !editor.searching {
+		editor.searching = true
+		editor.search = nil
+		editor.searchIndex = -1
+		editor.find(len(editor.history) - 1)
+	} else {
+		// Ctrl-R again finds the one before.
+		editor.find(editor.searchIndex - 1)
+	}
+
+	editor.render()
+}
+
+
+// find searches the history, backwards from entry 'from', for what is being searched for.
+func (editor *internalLineEditor) find(from int) {
+	if len(editor.history) <= from {
+		from = len(editor.history) - 1
+	}
+	if from < 0 {
+		from = len(editor.history) - 1
+	}
+
+	search := string(editor.search)
+	for i := from; 0 <= i; i-- {
+		if strings.Contains(editor.history[i], search) {
+			editor.searchIndex = i
+			return
+		}
+	}
+}
+
+
+// searchKey deals with a key typed while searching the history. It returns true if that is all
+// there is to do with the key; else the search is over, and the key is dealt with as usual.
+func (editor *internalLineEditor) searchKey(b byte) bool {
+	switch b {
+	case 0x12: // Ctrl-R
+		editor.startSearch()
+		return true
+	case 0x07: // Ctrl-G
+		editor.searching = false
+		editor.render()
+		return true
+	case 0x08, 0x7F: // Ctrl-H, Backspace
+		if 0 < len(editor.search) {
+			editor.search = editor.search[:len(editor.search)-1]
+			editor.searchIndex = -1
+			editor.find(len(editor.history) - 1)
+		}
+		editor.render()
+		return true
+	}
+
+	if 0x20 <= b {
+		editor.insert(rune(b))
+		return true
+	}
+
+	editor.acceptSearch()
+	return false
+}
+
+
+// acceptSearch ends the search, with what was found becoming the line being edited.
+func (editor *internalLineEditor) acceptSearch() {
+	editor.searching = false
+	if 0 <= editor.searchIndex && editor.searchIndex < len(editor.history) {
+		editor.historyIndex = len(editor.history)
+		editor.setLine([]rune(editor.history[editor.searchIndex]))
+	}
+	editor.render()
+}
+
+
+// completion completes what was typed (up to the cursor). If there is more than one possible
+// completion, it completes as much as they have in common; and, if 'list' is true (i.e., Tab
+// was typed twice), it lists them.
+func (editor *internalLineEditor) completion(list bool) {
+	if nil == editor.complete {
+		io.WriteString(editor.out, "\a")
+		return
+	}
+
+	typed := string(editor.line[:editor.pos])
+	rest := editor.line[editor.pos:]
+
+	candidates := editor.complete(typed)
+
+	var completed string
+	switch len(candidates) {
+	case 0:
+		io.WriteString(editor.out, "\a")
+		return
+	case 1:
+		completed = candidates[0]
+	default:
+		completed = commonPrefix(candidates)
+	}
+
+	if len(typed) < len(completed) && strings.HasPrefix(completed, typed) {
+		line := []rune(completed)
+		editor.pos = len(line)
+		editor.line = append(line, rest...)
+		editor.render()
+		return
+	}
+
+	if 1 < len(candidates) && list {
+		editor.moveTo(len(editor.line))
+		fmt.Fprintf(editor.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		editor.redraw()
+		return
+	}
+
+	io.WriteString(editor.out, "\a")
+}
+
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+
+	return prefix
+}
+
+
+// redraw draws the prompt and the line being edited, from the start of the (screen) line.
+func (editor *internalLineEditor) redraw() {
+	io.WriteString(editor.out, "\r")
+	if nil != editor.prompt {
+		io.WriteString(editor.out, editor.prompt())
+	}
+	editor.cursor = 0
+
+	editor.render()
+}
+
+
+// display returns what should be drawn, and where the cursor should be in it.
+func (editor *internalLineEditor) display() (string, int) {
+	if !editor.searching {
+		return string(editor.line), editor.pos
+	}
+
+	var found string
+	if 0 <= editor.searchIndex && editor.searchIndex < len(editor.history) {
+		found = editor.history[editor.searchIndex]
+	}
+
+	text := "(reverse-i-search)`" + string(editor.search) + "': " + found
+	return text, utf8.RuneCountInString(text)
+}
+
+
+// render draws the line being edited (over what it drew before), and puts the cursor in place.
+func (editor *internalLineEditor) render() {
+	text, cursor := editor.display()
+
+	var buffer bytes.Buffer
+
+	if 0 < editor.cursor {
+		fmt.Fprintf(&buffer, "\x1b[%dD", editor.cursor)
+	}
+	buffer.WriteString(text)
+	buffer.WriteString("\x1b[K")
+	if back := utf8.RuneCountInString(text) - cursor; 0 < back {
+		fmt.Fprintf(&buffer, "\x1b[%dD", back)
+	}
+
+	editor.cursor = cursor
+
+	editor.out.Write(buffer.Bytes())
+}
+
+
+// historyPath returns the name of the history file, in 'dir', for the host 'addr'.
+func historyPath(dir string, addr string) string {
+	host := addr
+	if i := strings.LastIndexByte(addr, ':'); 0 <= i && !strings.HasSuffix(addr, "]") {
+		host = addr[:i]
+	}
+	host = strings.Trim(host, "[]")
+	host = strings.Map(func(r rune) rune {
+		if '/' == r || '\\' == r || os.PathSeparator == r {
+			return '_'
+		}
+		return r
+	}, host)
+
+	if "" == host || "." == host || ".." == host {
+		host = "_"
+	}
+
+	return filepath.Join(dir, host)
+}
+
+
+// loadHistory reads the history file 'path'; one entry per line. (A missing file is just an
+// empty history.)
+//
+// Since appendHistory only ever adds to the file, loadHistory is also what keeps it from
+// growing without limit: if the file has more than maxLineEditorHistory lines, it is
+// rewritten with just the last of them.
+func loadHistory(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	var history []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		history = append(history, scanner.Text())
+	}
+	if err := scanner.Err(); nil != err {
+		return history, err
+	}
+
+	if maxLineEditorHistory < len(history) {
+		history = history[len(history)-maxLineEditorHistory:]
+		return history, writeHistory(path, history)
+	}
+
+	return history, nil
+}
+
+
+// writeHistory replaces the history file 'path' with 'history'; one entry per line.
+//
+// The new file is written next to the old one, and then renamed over it; so a crash does not
+// leave a half-written file.
+func writeHistory(path string, history []string) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if nil != err {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, line := range history {
+		w.WriteString(line + "\n")
+	}
+	if err := w.Flush(); nil != err {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); nil != err {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+
+// appendHistory adds 'line' to the end of the history file 'path'.
+func appendHistory(path string, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); nil != err {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return err
+	}
+
+	if _, err := io.WriteString(file, line + "\n"); nil != err {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,323 @@
+package telnet
+
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"testing"
+)
+
+
+func TestLineEditor(t *testing.T) {
+
+	complete := func(line string) []string {
+		var candidates []string
+		for _, command := range []string{"show version", "show interfaces", "shutdown", "exit"} {
+			if strings.HasPrefix(command, line) {
+				candidates = append(candidates, command)
+			}
+		}
+		return candidates
+	}
+
+	tests := []struct{
+		History  []string
+		Typed    string
+		Expected []string
+	}{
+		{
+			Typed:    "apple\r",
+			Expected: []string{"apple"},
+		},
+		{
+			Typed:    "apple\rbanana\n",
+			Expected: []string{"apple", "banana"},
+		},
+		{
+			Typed:    "apple\r\nbanana\r\n",
+			Expected: []string{"apple", "banana"},
+		},
+		{
+			Typed:    "\r",
+			Expected: []string{""},
+		},
+		{
+			Typed:    "caf\xc3\xa9\r",
+			Expected: []string{"caf\xc3\xa9"},
+		},
+
+
+
+		{
+			Typed:    "applx\x7fe\r", // Backspace
+			Expected: []string{"apple"},
+		},
+		{
+			Typed:    "pple\x01a\r", // Ctrl-A
+			Expected: []string{"apple"},
+		},
+		{
+			Typed:    "appe\x1b[Dl\x05!\r", // Left, Ctrl-E
+			Expected: []string{"apple!"},
+		},
+		{
+			Typed:    "apple\x1b[D\x1b[D\x1b[3~\r", // Left, Left, Delete
+			Expected: []string{"appe"},
+		},
+		{
+			Typed:    "apple banana\x17cherry\r", // Ctrl-W
+			Expected: []string{"apple cherry"},
+		},
+		{
+			Typed:    "apple banana\x1bbred \r", // Alt-B
+			Expected: []string{"apple red banana"},
+		},
+		{
+			Typed:    "apple banana\x01\x1bd\r", // Ctrl-A, Alt-D
+			Expected: []string{" banana"},
+		},
+		{
+			Typed:    "apple banana\x1b[1;5D\x0b\r", // Ctrl-Left, Ctrl-K
+			Expected: []string{"apple "},
+		},
+		{
+			Typed:    "apple banana\x1b[1;5D\x15\r", // Ctrl-Left, Ctrl-U
+			Expected: []string{"banana"},
+		},
+
+
+
+		{
+			History:  []string{"apple", "banana"},
+			Typed:    "\x1b[A\r", // Up
+			Expected: []string{"banana"},
+		},
+		{
+			History:  []string{"apple", "banana"},
+			Typed:    "\x10\x10\x10\r", // Ctrl-P x3
+			Expected: []string{"apple"},
+		},
+		{
+			History:  []string{"apple", "banana"},
+			Typed:    "cherry\x1b[A\x1b[B\r", // Up, Down
+			Expected: []string{"cherry"},
+		},
+		{
+			Typed:    "apple\rbanana\r\x1b[A\x1b[A!\r",
+			Expected: []string{"apple", "banana", "apple!"},
+		},
+
+
+
+		{
+			History:  []string{"show version", "exit", "show interfaces"},
+			Typed:    "\x12ver\r", // Ctrl-R
+			Expected: []string{"show version"},
+		},
+		{
+			History:  []string{"show version", "exit", "show interfaces"},
+			Typed:    "\x12show\x12\r", // Ctrl-R, Ctrl-R again
+			Expected: []string{"show version"},
+		},
+		{
+			History:  []string{"show version", "exit", "show interfaces"},
+			Typed:    "\x12exit\x05!\r", // Ctrl-R, then Ctrl-E to edit
+			Expected: []string{"exit!"},
+		},
+		{
+			History:  []string{"show version", "exit"},
+			Typed:    "abc\x12exit\x07\r", // Ctrl-R, Ctrl-G to cancel
+			Expected: []string{"abc"},
+		},
+
+
+
+		{
+			Typed:    "e\t\r", // Tab
+			Expected: []string{"exit"},
+		},
+		{
+			Typed:    "sh\t\r",
+			Expected: []string{"sh"},
+		},
+		{
+			Typed:    "show \t\tv\t\r",
+			Expected: []string{"show version"},
+		},
+		{
+			Typed:    "x\t\r",
+			Expected: []string{"x"},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		editor := newLineEditor(ioutil.Discard, append([]string(nil), test.History...))
+		editor.complete = complete
+
+		var lines []string
+		for _, b := range []byte(test.Typed) {
+			if line, action := editor.feed(b); lineEditorLine == action {
+				lines = append(lines, line)
+			}
+		}
+
+		if expected, actual := test.Expected, lines; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestLineEditorActions(t *testing.T) {
+
+	tests := []struct{
+		Typed    string
+		Expected lineEditorAction
+	}{
+		{
+			Typed:    "abc\x03", // Ctrl-C
+			Expected: lineEditorInterrupt,
+		},
+		{
+			Typed:    "\x04", // Ctrl-D
+			Expected: lineEditorEOF,
+		},
+		{
+			Typed:    "abc\x04", // Ctrl-D, not on an empty line
+			Expected: lineEditorNone,
+		},
+		{
+			Typed:    "abc\x1a", // Ctrl-Z
+			Expected: lineEditorSuspend,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		editor := newLineEditor(ioutil.Discard, nil)
+
+		var action lineEditorAction
+		for _, b := range []byte(test.Typed) {
+			_, action = editor.feed(b)
+		}
+
+		if expected, actual := test.Expected, action; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestLineEditorHistoryFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "telnet-history")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := historyPath(dir, "192.0.2.1:23")
+
+	editor := newLineEditor(ioutil.Discard, nil)
+	editor.onHistory = func(line string) {
+		if err := appendHistory(path, line); nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+	for _, b := range []byte("apple\rapple\r\rbanana\r") {
+		editor.feed(b)
+	}
+
+	history, err := loadHistory(path)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []string{"apple", "banana"}, history; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	history, err = loadHistory(filepath.Join(dir, "missing"))
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if 0 != len(history) {
+		t.Errorf("Expected no history, but actually got %q.", history)
+	}
+
+	// A history file that has grown past the limit is cut back when it is loaded.
+	var lines []string
+	for i := 0; i < maxLineEditorHistory+5; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	for i := 0; i < 2; i++ {
+		history, err = loadHistory(path)
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		if expected, actual := lines[5:], history; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For load #%d, expected the last %d lines, but actually got %d lines.", i, len(expected), len(actual))
+		}
+	}
+	contents, err := ioutil.ReadFile(path)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := strings.Join(lines[5:], "\n")+"\n", string(contents); expected != actual {
+		t.Errorf("Expected the history file to be compacted to %d lines, but actually got %d.", maxLineEditorHistory, strings.Count(actual, "\n"))
+	}
+	files, err := ioutil.ReadDir(dir)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := 1, len(files); expected != actual {
+		t.Errorf("Expected %d file in the history directory, but actually got %d.", expected, actual)
+	}
+}
+
+
+func TestHistoryPath(t *testing.T) {
+
+	tests := []struct{
+		Addr     string
+		Expected string
+	}{
+		{
+			Addr:     "192.0.2.1:23",
+			Expected: "192.0.2.1",
+		},
+		{
+			Addr:     "[2001:db8::1]:23",
+			Expected: "2001:db8::1",
+		},
+		{
+			Addr:     "/var/run/telnet.sock",
+			Expected: "_var_run_telnet.sock",
+		},
+		{
+			Addr:     "..",
+			Expected: "_",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		if expected, actual := filepath.Join("dir", test.Expected), historyPath("dir", test.Addr); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
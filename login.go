@@ -0,0 +1,152 @@
+package telnet
+
+
+import (
+	"github.com/reiver/go-telnet/internal/prompt"
+
+	"bytes"
+	"errors"
+	"net"
+	"regexp"
+	"time"
+)
+
+
+const defaultLoginTimeout = 10 * time.Second
+
+
+var shellPrompt = regexp.MustCompile(`[$#>%] ?$`)
+
+
+// A LoginError is returned (by Client.Call, etc) when automatic login fails; for example,
+// because the server said "Login incorrect".
+type LoginError struct {
+	Message string // what the server said, if anything; e.g., "Login incorrect".
+}
+
+
+func (err *LoginError) Error() string {
+	if "" == err.Message {
+		return "Login failed."
+	}
+
+	return "Login failed: " + err.Message
+}
+
+
+// login logs in, if the Client has credentials.
+//
+// The user name is sent with NEW-ENVIRON (if the server asks for it). Then login waits for
+// the common login and password prompts (such as "login:" and "Password:"), and answers them,
+// until a shell prompt (such as "$ " or "router#") shows up.
+//
+// A login failure message (such as "Login incorrect") only counts if it is a line of its own,
+// and the login (or password) prompt comes next, or the server hangs up (or goes quiet). So a
+// banner or message of the day that mentions (for example) "invalid" does not fail the login.
+//
+// If nothing recognizable shows up within the login timeout (which starts over after each
+// prompt is answered), then login assumes no login was needed, and leaves what was received
+// to be read by the Caller. (As it does with whatever came after the last prompt it answered.)
+func (client *Client) login(conn *Conn) error {
+
+	username, password, err := client.credentials()
+	if nil != err {
+		return err
+	}
+	if "" == username && "" == password {
+		return nil
+	}
+
+	logger := client.logger()
+
+	// The protocol-level way of sending the user name. (If the server asks for it.)
+	conn.ctx.InjectUser(username)
+
+	timeout := client.LoginTimeout
+	if timeout <= 0 {
+		timeout = defaultLoginTimeout
+	}
+
+	// The timeout is for each prompt; so the deadline is set again after each prompt is
+	// answered. A connection that does not support deadlines does not make the login fail;
+	// but then login waits until a shell prompt shows up (or the connection ends).
+	setDeadline := func() {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); nil != err {
+			logger.Debugf("Could not set the login deadline: %v", err)
+		}
+	}
+	setDeadline()
+	defer conn.SetReadDeadline(time.Time{})
+
+	var received []byte
+	sentUsername, sentPassword := false, false
+
+	var buffer [1024]byte
+	p := buffer[:]
+
+	// failed returns the error for a failed login, with the failure message (if one was received).
+	failed := func() error {
+		return &LoginError{Message:string(bytes.TrimSpace(prompt.LoginFailure.Find(received)))}
+	}
+
+	for {
+		switch {
+		case prompt.Login.Match(received):
+			if sentUsername {
+				return failed()
+			}
+			logger.Debug("Answering login prompt.")
+			if _, err := conn.Write([]byte(username + "\r\n")); nil != err {
+				return err
+			}
+			sentUsername = true
+			received = nil
+			setDeadline()
+			continue
+		case prompt.Password.Match(received):
+			if sentPassword {
+				return failed()
+			}
+			logger.Debug("Answering password prompt.")
+			if _, err := conn.write([]byte(password + "\r\n"), true); nil != err {
+				return err
+			}
+			sentPassword = true
+			received = nil
+			setDeadline()
+			continue
+		case shellPrompt.Match(received):
+			logger.Debug("Logged in.")
+			conn.unread(received)
+			return nil
+		}
+
+		// Reading from the data reader (rather than from the Conn) so that a timeout here
+		// does not count as the session timing out.
+		n, err := conn.readData(p)
+		received = append(received, p[:n]...)
+
+		if nil != err && (sentUsername || sentPassword) && prompt.LoginFailure.Match(received) {
+			return failed()
+		}
+
+		var netError net.Error
+		if errors.As(err, &netError) && netError.Timeout() {
+			logger.Debug("No (more) login prompts.")
+			conn.unread(received)
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+	}
+}
+
+
+func (client *Client) credentials() (username string, password string, err error) {
+	if nil != client.Credentials {
+		return client.Credentials()
+	}
+
+	return client.Username, client.Password, nil
+}
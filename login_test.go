@@ -0,0 +1,380 @@
+package telnet
+
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+
+// serveLogin is a (minimal) stand-in for a server that asks for a login and password.
+func serveLogin(conn net.Conn) {
+	defer conn.Close()
+
+	lines := bufio.NewReader(conn)
+	readLine := func() string {
+		line, _ := lines.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	io.WriteString(conn, "Ubuntu 22.04\r\nhost login: ")
+	username := readLine()
+	io.WriteString(conn, username+"\r\nPassword: ")
+	password := readLine()
+
+	if "joe" != username || "secret" != password {
+		io.WriteString(conn, "\r\nLogin incorrect\r\nhost login: ")
+		readLine()
+		return
+	}
+
+	io.WriteString(conn, "\r\nWelcome!\r\njoe@host:~$ ")
+	readLine()
+}
+
+
+func TestClientLogin(t *testing.T) {
+
+	tests := []struct{
+		Username string
+		Password string
+		Serve    func(net.Conn)
+
+		Expected      string
+		ExpectedLogin bool
+	}{
+		{
+			Username: "joe",
+			Password: "secret",
+			Serve:    serveLogin,
+			Expected: "\r\nWelcome!\r\njoe@host:~$ ",
+		},
+		{
+			Username: "joe",
+			Password: "wrong",
+			Serve:    serveLogin,
+			ExpectedLogin: true,
+		},
+		{
+			// A message of the day that mentions a login failure is not one.
+			Username: "joe",
+			Password: "secret",
+			Serve:    func(conn net.Conn) {
+				defer conn.Close()
+				lines := bufio.NewReader(conn)
+				io.WriteString(conn, "login: ")
+				lines.ReadString('\n')
+				io.WriteString(conn, "Password: ")
+				lines.ReadString('\n')
+				io.WriteString(conn, "\r\nThere were 2 invalid login attempts since the last login.\r\nAccess denied\r\n$ ")
+				lines.ReadString('\n')
+			},
+			Expected: "\r\nThere were 2 invalid login attempts since the last login.\r\nAccess denied\r\n$ ",
+		},
+		{
+			// No prompts at all. (So, no login is needed.)
+			Username: "joe",
+			Password: "secret",
+			Serve:    func(conn net.Conn) {
+				defer conn.Close()
+				io.WriteString(conn, "Hello")
+				time.Sleep(100 * time.Millisecond)
+			},
+			Expected: "Hello",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		go func(serve func(net.Conn)) {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			serve(conn)
+		}(test.Serve)
+
+		var received []byte
+
+		client := Client{
+			Username:     test.Username,
+			Password:     test.Password,
+			LoginTimeout: 50 * time.Millisecond,
+			Caller:       CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				p := make([]byte, 1024)
+				n, err := r.Read(p)
+				received = p[:n]
+				w.Write([]byte("exit\r\n"))
+				return err
+			}),
+		}
+
+		conn, err := DialTo(listener.Addr().String())
+		if nil != err {
+			listener.Close()
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+
+		err = client.Call(conn)
+		listener.Close()
+
+		var loginError *LoginError
+		if expected, actual := test.ExpectedLogin, errors.As(err, &loginError); expected != actual {
+			t.Errorf("For test #%d, expected a *LoginError to be %t, but actually was %t: (%T) %v", testNumber, expected, actual, err, err)
+			continue
+		}
+		if test.ExpectedLogin {
+			if expected, actual := "Login incorrect", loginError.Message; expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, string(received); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestClientLoginNewEnviron(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	users := make(chan string, 1)
+
+	handler := connTestHandler(func(ctx Context, w Writer, r Reader) {
+		r.(*Conn).RequestUser()
+		w.Write([]byte("login: "))
+		ioutil.ReadAll(io.LimitReader(r, int64(len("joe\r\n"))))
+
+		// The NEW-ENVIRON reply might come after the answer to the login prompt, so keep
+		// reading for a bit.
+		r.(*Conn).SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		r.Read(make([]byte, 16))
+		r.(*Conn).SetReadDeadline(time.Time{})
+
+		users <- ctx.(TerminalContext).User()
+		w.Write([]byte("$ "))
+	})
+	go Serve(listener, handler)
+
+	client := Client{
+		Caller: CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			return nil
+		}),
+	}
+	client.SetAuth("joe")
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := client.Call(conn); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	select {
+	case user := <-users:
+		if expected, actual := "joe", user; expected != actual {
+			t.Errorf("Expected the server to get the user %q (with NEW-ENVIRON), but actually got %q.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The server did not get the user.")
+	}
+}
+
+
+func TestClientLoginTranscript(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if nil != err {
+			return
+		}
+		serveLogin(conn)
+	}()
+
+	var buffer bytes.Buffer
+
+	client := Client{
+		Username:     "joe",
+		Password:     "secret",
+		LoginTimeout: 50 * time.Millisecond,
+		Transcript:   &Transcript{Writer:&buffer, Format:TranscriptTimestamped, MaskHiddenInput:true},
+		Caller:       CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			_, err := w.Write([]byte("exit\r\n"))
+			return err
+		}),
+	}
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := client.Call(conn); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	transcript := buffer.String()
+
+	for _, expected := range []string{` send "joe\r\n"`, ` send-hidden "******\r\n"`, ` send "exit\r\n"`} {
+		if !strings.Contains(transcript, expected) {
+			t.Errorf("Expected the transcript to contain %q, but it did not: %q", expected, transcript)
+		}
+	}
+	if strings.Contains(transcript, "secret") {
+		t.Errorf("Did not expect the transcript to contain the password, but it did: %q", transcript)
+	}
+}
+
+
+func TestClientLoginTimeoutIsPerPrompt(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	// Each prompt shows up well within the login timeout of the one before it; but not
+	// within the login timeout of the start of the login.
+	go func() {
+		conn, err := listener.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+
+		lines := bufio.NewReader(conn)
+
+		time.Sleep(120 * time.Millisecond)
+		io.WriteString(conn, "host login: ")
+		lines.ReadString('\n')
+		time.Sleep(120 * time.Millisecond)
+		io.WriteString(conn, "Password: ")
+		lines.ReadString('\n')
+		io.WriteString(conn, "\r\njoe@host:~$ ")
+		lines.ReadString('\n')
+	}()
+
+	var received []byte
+
+	client := Client{
+		Username:     "joe",
+		Password:     "secret",
+		LoginTimeout: 200 * time.Millisecond,
+		Caller:       CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			p := make([]byte, 1024)
+			n, err := r.Read(p)
+			received = p[:n]
+			w.Write([]byte("exit\r\n"))
+			return err
+		}),
+	}
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := client.Call(conn); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "\r\njoe@host:~$ ", string(received); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+// loginTestNoDeadlineConn is a connection that does not support deadlines.
+type loginTestNoDeadlineConn struct {
+	net.Conn
+}
+
+
+func (loginTestNoDeadlineConn) SetDeadline(time.Time) error {
+	return errors.New("Deadlines are not supported.")
+}
+
+
+func (loginTestNoDeadlineConn) SetReadDeadline(time.Time) error {
+	return errors.New("Deadlines are not supported.")
+}
+
+
+func TestClientLoginWithoutDeadlines(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if nil != err {
+			return
+		}
+		serveLogin(conn)
+	}()
+
+	var received []byte
+
+	client := Client{
+		Username:     "joe",
+		Password:     "secret",
+		Caller:       CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			p := make([]byte, 1024)
+			n, err := r.Read(p)
+			received = p[:n]
+			w.Write([]byte("exit\r\n"))
+			return err
+		}),
+	}
+
+	c, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := client.Call(NewConn(loginTestNoDeadlineConn{c}, nil)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "\r\nWelcome!\r\njoe@host:~$ ", string(received); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
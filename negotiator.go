@@ -0,0 +1,642 @@
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+)
+
+
+// An internalNegotiator deals with TELNET (and TELNETS) option negotiation.
+//
+// I.e., it answers (and, when appropriate, sends) the WILL, WON'T, DO and DON'T commands,
+// and deals with the subnegotiations (IAC SB ... IAC SE) of the options it supports.
+//
+// Which options are accepted depends on whether this is the server side or the client
+// side of the connection.
+//
+// Commands are written to the wrapped io.Writer as-is (i.e., they are not escaped
+// the way data is).
+type internalNegotiator struct {
+	mutex sync.Mutex
+
+	wrapped io.Writer
+	ctx     *internalContext
+	server  bool
+
+	local  [256]bool // options we are performing.
+	remote [256]bool // options the other side is performing.
+
+	pendingLocal  [256]bool // options we sent a WILL for, and have not heard back about.
+	pendingRemote [256]bool // options we sent a DO for, and have not heard back about.
+
+	binary bool // whether TRANSMIT-BINARY is accepted (in both directions). (See Conn.RequestBinary.)
+
+	flowControl bool // whether TOGGLE-FLOW-CONTROL is accepted. (Client side; see Conn.AcceptFlowControl.)
+
+	flow  *internalFlowControl // (client side.)
+	lflow []byte               // the LFLOW commands to send, once the client does TOGGLE-FLOW-CONTROL. (Server side; see setFlowControl.)
+
+	writeClosed     chan struct{} // closed once this side has half-closed the connection. (See Conn.CloseWrite.)
+	writeClosedOnce sync.Once
+
+	// Hooks, for handlers (such as ExecHandler) that need to act as things arrive. Either can be
+	// nil. (See setHooks.)
+	onOption  func(option byte)  // called after a subnegotiation from the other side, or when it refuses (or stops) an option.
+	onCommand func(command byte) // called when the other side sends BRK, IP, AO, AYT, EC or EL.
+}
+
+
+// newNegotiator creates a new internalNegotiator that writes commands to 'w', and
+// records what it learns in 'ctx'.
+func newNegotiator(w io.Writer, ctx *internalContext, server bool) *internalNegotiator {
+	negotiator := internalNegotiator{
+		wrapped:w,
+		ctx:ctx,
+		server:server,
+		flow:newFlowControl(),
+		writeClosed:make(chan struct{}),
+	}
+
+	return &negotiator
+}
+
+
+func (n *internalNegotiator) logger() Logger {
+	logger := n.ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	return logger
+}
+
+
+// localEnabled reports whether we are performing 'option'.
+func (n *internalNegotiator) localEnabled(option byte) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.local[option]
+}
+
+// remoteEnabled reports whether the other side is performing 'option'.
+func (n *internalNegotiator) remoteEnabled(option byte) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.remote[option]
+}
+
+
+// acceptsLocal reports whether we are willing to perform 'option' if the other side asks us to (with a DO).
+func (n *internalNegotiator) acceptsLocal(option byte) bool {
+	if n.server {
+		switch option {
+		case optionSuppressGoAhead, optionEndOfRecord:
+			return true
+		default:
+			return false
+		}
+	}
+
+	switch option {
+	case optionBinary:
+		return n.binary
+	case optionTerminalType:
+		return "" != n.ctx.TerminalType()
+	case optionWindowSize:
+		width, height := n.ctx.WindowSize()
+		return 0 < width && 0 < height
+	case optionTerminalSpeed:
+		transmit, receive := n.ctx.TerminalSpeed()
+		return 0 < transmit && 0 < receive
+	case optionXDisplayLocation:
+		return "" != n.ctx.XDisplayLocation()
+	case optionSendLocation:
+		return "" != n.ctx.Location()
+	case optionNewEnviron:
+		return "" != n.ctx.User()
+	case optionToggleFlowControl:
+		return n.flowControl
+	default:
+		return false
+	}
+}
+
+
+// acceptsRemote reports whether we are willing to let the other side perform 'option' if it offers to (with a WILL).
+func (n *internalNegotiator) acceptsRemote(option byte) bool {
+	if !n.server {
+		switch option {
+		case optionBinary:
+			return n.binary
+		case optionEcho, optionSuppressGoAhead, optionEndOfRecord:
+			return true
+		default:
+			return false
+		}
+	}
+
+	switch option {
+	case optionSuppressGoAhead, optionTerminalType, optionWindowSize, optionTerminalSpeed, optionXDisplayLocation, optionSendLocation, optionToggleFlowControl, optionNewEnviron:
+		return true
+	default:
+		return false
+	}
+}
+
+
+// negotiate deals with a received WILL, WON'T, DO or DON'T 'command' for 'option'.
+func (n *internalNegotiator) negotiate(command byte, option byte) error {
+
+	logger := n.logger()
+
+	n.mutex.Lock()
+
+	var reply byte
+	var enabled, disabled, refused bool
+
+	switch command {
+	case cmdWILL:
+		logger.Tracef("Received WILL %d.", option)
+		switch {
+		case n.remote[option]:
+			// Already enabled. Nothing to do.
+		case n.pendingRemote[option]:
+			n.pendingRemote[option] = false
+			n.remote[option] = true
+			enabled = true
+		case n.acceptsRemote(option):
+			n.remote[option] = true
+			reply = cmdDO
+			enabled = true
+		default:
+			reply = cmdDONT
+		}
+	case cmdWONT:
+		logger.Tracef("Received WON'T %d.", option)
+		switch {
+		case n.remote[option]:
+			n.remote[option] = false
+			reply = cmdDONT
+			disabled = true
+		case n.pendingRemote[option]:
+			n.pendingRemote[option] = false
+			refused = true
+		}
+	case cmdDO:
+		logger.Tracef("Received DO %d.", option)
+		switch {
+		case n.local[option]:
+			// Already enabled. Nothing to do.
+		case n.pendingLocal[option]:
+			n.pendingLocal[option] = false
+			n.local[option] = true
+			enabled = true
+		case n.acceptsLocal(option):
+			n.local[option] = true
+			reply = cmdWILL
+			enabled = true
+		default:
+			reply = cmdWONT
+		}
+	case cmdDONT:
+		logger.Tracef("Received DON'T %d.", option)
+		switch {
+		case n.local[option]:
+			n.local[option] = false
+			reply = cmdWONT
+			disabled = true
+		case n.pendingLocal[option]:
+			n.pendingLocal[option] = false
+		}
+	}
+
+	n.mutex.Unlock()
+
+
+	if 0 != reply {
+		if err := n.command(reply, option); nil != err {
+			return err
+		}
+	}
+
+	local := cmdDO == command || cmdDONT == command
+
+	switch {
+	case enabled:
+		return n.onEnabled(option, local)
+	case disabled:
+		return n.onDisabled(option, local)
+	case refused:
+		n.notifyOption(option)
+	}
+
+	return nil
+}
+
+
+// offerLocal sends a WILL for 'option', unless it is already enabled (or already offered).
+func (n *internalNegotiator) offerLocal(option byte) error {
+	n.mutex.Lock()
+	if n.local[option] || n.pendingLocal[option] {
+		n.mutex.Unlock()
+		return nil
+	}
+	n.pendingLocal[option] = true
+	n.mutex.Unlock()
+
+	return n.command(cmdWILL, option)
+}
+
+// offerRemote sends a DO for 'option', unless it is already enabled (or already asked for).
+func (n *internalNegotiator) offerRemote(option byte) error {
+	n.mutex.Lock()
+	if n.remote[option] || n.pendingRemote[option] {
+		n.mutex.Unlock()
+		return nil
+	}
+	n.pendingRemote[option] = true
+	n.mutex.Unlock()
+
+	return n.command(cmdDO, option)
+}
+
+
+// requestBinary asks for TRANSMIT-BINARY in both directions; and makes it accepted from now on.
+func (n *internalNegotiator) requestBinary() error {
+	n.mutex.Lock()
+	n.binary = true
+	n.mutex.Unlock()
+
+	if err := n.offerLocal(optionBinary); nil != err {
+		return err
+	}
+
+	return n.offerRemote(optionBinary)
+}
+
+
+// acceptFlowControl makes it so TOGGLE-FLOW-CONTROL is accepted, if the server asks for it.
+func (n *internalNegotiator) acceptFlowControl() {
+	n.mutex.Lock()
+	n.flowControl = true
+	n.mutex.Unlock()
+}
+
+
+// setFlowControl tells the client to turn its local flow control on or off, and whether any
+// character (rather than just an XON) restarts its output; with TOGGLE-FLOW-CONTROL. If the
+// client is not doing TOGGLE-FLOW-CONTROL yet, then it is asked to, and this is sent once it
+// does.
+func (n *internalNegotiator) setFlowControl(on bool, restartAny bool) error {
+	commands := []byte{lflowOFF, lflowRESTARTXON}
+	if on {
+		commands[0] = lflowON
+	}
+	if restartAny {
+		commands[1] = lflowRESTARTANY
+	}
+
+	n.mutex.Lock()
+	n.lflow = commands
+	enabled := n.remote[optionToggleFlowControl]
+	n.mutex.Unlock()
+
+	if !enabled {
+		return n.offerRemote(optionToggleFlowControl)
+	}
+
+	return n.sendFlowControl(commands)
+}
+
+
+// sendFlowControl sends each of the LFLOW 'commands' (such as ON, or RESTART-ANY) to the client.
+func (n *internalNegotiator) sendFlowControl(commands []byte) error {
+	for _, command := range commands {
+		if err := n.subnegotiation(optionToggleFlowControl, []byte{command}); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// sendWindowSize sends the window size (from the context) with NAWS; if we are doing NAWS.
+// It is used when NAWS gets enabled, and whenever the window size changes.
+func (n *internalNegotiator) sendWindowSize() error {
+	if !n.localEnabled(optionWindowSize) {
+		return nil
+	}
+
+	width, height := n.ctx.WindowSize()
+	if width < 0 || 0xFFFF < width || height < 0 || 0xFFFF < height {
+		return nil
+	}
+
+	return n.subnegotiation(optionWindowSize, []byte{byte(width>>8), byte(width), byte(height>>8), byte(height)})
+}
+
+
+// refuseRemote sends a DON'T for 'option', if the other side is performing it (or was asked to).
+func (n *internalNegotiator) refuseRemote(option byte) error {
+	n.mutex.Lock()
+	if !n.remote[option] && !n.pendingRemote[option] {
+		n.mutex.Unlock()
+		return nil
+	}
+	wasEnabled := n.remote[option]
+	n.remote[option] = false
+	n.pendingRemote[option] = false
+	n.mutex.Unlock()
+
+	if err := n.command(cmdDONT, option); nil != err {
+		return err
+	}
+
+	if wasEnabled {
+		return n.onDisabled(option, false)
+	}
+
+	return nil
+}
+
+
+// onEnabled is called when 'option' has become enabled. If 'local' is true, then we
+// are the side performing the option, else it is the other side.
+func (n *internalNegotiator) onEnabled(option byte, local bool) error {
+	n.logger().Debugf("Enabled option %d (local=%t).", option, local)
+
+	if local {
+		switch option {
+		case optionWindowSize:
+			return n.sendWindowSize()
+		case optionSendLocation:
+			return n.subnegotiation(optionSendLocation, []byte(n.ctx.Location()))
+		case optionToggleFlowControl:
+			n.flow.enable(true)
+		case optionBinary:
+			n.flow.setBinary(true)
+		}
+
+		return nil
+	}
+
+	switch option {
+	case optionTerminalType, optionTerminalSpeed, optionXDisplayLocation:
+		return n.subnegotiation(option, []byte{subSEND})
+	case optionNewEnviron:
+		return n.subnegotiation(option, append([]byte{subSEND, environVAR}, environUser...))
+	case optionToggleFlowControl:
+		n.mutex.Lock()
+		commands := n.lflow
+		n.mutex.Unlock()
+
+		return n.sendFlowControl(commands)
+	}
+
+	return nil
+}
+
+
+// onDisabled is called when 'option' has become disabled.
+func (n *internalNegotiator) onDisabled(option byte, local bool) error {
+	n.logger().Debugf("Disabled option %d (local=%t).", option, local)
+
+	if local {
+		switch option {
+		case optionToggleFlowControl:
+			n.flow.enable(false)
+		case optionBinary:
+			n.flow.setBinary(false)
+		}
+
+		return nil
+	}
+
+	n.notifyOption(option)
+
+	return nil
+}
+
+
+// subnegotiate deals with a received IAC SB 'option' ... IAC SE, where 'data' is
+// the (un-escaped) bytes between the option and the IAC SE.
+func (n *internalNegotiator) subnegotiate(option byte, data []byte) error {
+	n.logger().Tracef("Received subnegotiation for option %d: %q", option, data)
+
+	if n.localEnabled(option) {
+		switch option {
+		case optionTerminalType:
+			if 1 <= len(data) && subSEND == data[0] {
+				return n.subnegotiation(option, append([]byte{subIS}, n.ctx.TerminalType()...))
+			}
+		case optionTerminalSpeed:
+			if 1 <= len(data) && subSEND == data[0] {
+				transmit, receive := n.ctx.TerminalSpeed()
+				value := strconv.Itoa(transmit) + "," + strconv.Itoa(receive)
+				return n.subnegotiation(option, append([]byte{subIS}, value...))
+			}
+		case optionXDisplayLocation:
+			if 1 <= len(data) && subSEND == data[0] {
+				return n.subnegotiation(option, append([]byte{subIS}, n.ctx.XDisplayLocation()...))
+			}
+		case optionNewEnviron:
+			if 1 <= len(data) && subSEND == data[0] {
+				return n.subnegotiation(option, n.environ(parseEnviron(data[1:])))
+			}
+		case optionToggleFlowControl:
+			if 1 <= len(data) {
+				n.flow.command(data[0])
+			}
+		}
+	}
+
+	if n.remoteEnabled(option) {
+		switch option {
+		case optionTerminalType:
+			if 1 <= len(data) && subIS == data[0] {
+				n.ctx.InjectTerminalType(string(data[1:]))
+			}
+		case optionWindowSize:
+			if 4 != len(data) {
+				n.logger().Warnf("Received bad NAWS: %q", data)
+				return nil
+			}
+			n.ctx.InjectWindowSize(int(data[0])<<8|int(data[1]), int(data[2])<<8|int(data[3]))
+		case optionTerminalSpeed:
+			if 1 <= len(data) && subIS == data[0] {
+				transmit, receive, ok := parseTerminalSpeed(data[1:])
+				if !ok {
+					n.logger().Warnf("Received bad TERMINAL-SPEED: %q", data[1:])
+					return nil
+				}
+				n.ctx.InjectTerminalSpeed(transmit, receive)
+			}
+		case optionXDisplayLocation:
+			if 1 <= len(data) && subIS == data[0] {
+				n.ctx.InjectXDisplayLocation(string(data[1:]))
+			}
+		case optionSendLocation:
+			n.ctx.InjectLocation(string(data))
+		case optionNewEnviron:
+			if 1 <= len(data) && (subIS == data[0] || subINFO == data[0]) {
+				for _, variable := range parseEnviron(data[1:]) {
+					if environVAR == variable.Type && environUser == variable.Name && variable.HasValue {
+						n.ctx.InjectUser(variable.Value)
+					}
+				}
+			}
+		}
+
+		n.notifyOption(option)
+	}
+
+	return nil
+}
+
+
+// setHooks sets the functions called as things arrive from the other side. (See onOption and
+// onCommand.) They are called from whatever goroutine is reading; so they should not block.
+func (n *internalNegotiator) setHooks(onOption func(option byte), onCommand func(command byte)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.onOption = onOption
+	n.onCommand = onCommand
+}
+
+
+func (n *internalNegotiator) notifyOption(option byte) {
+	n.mutex.Lock()
+	onOption := n.onOption
+	n.mutex.Unlock()
+
+	if nil != onOption {
+		onOption(option)
+	}
+}
+
+
+// receivedCommand is called when the other side sends a command that does not have an option;
+// such as IP.
+func (n *internalNegotiator) receivedCommand(command byte) {
+	n.logger().Tracef("Received command %d.", command)
+
+	n.mutex.Lock()
+	onCommand := n.onCommand
+	n.mutex.Unlock()
+
+	if nil != onCommand {
+		onCommand(command)
+	}
+}
+
+
+// environ returns the NEW-ENVIRON IS reply to a SEND for 'requested'. (An empty 'requested'
+// means send everything.)
+//
+// The only variable we ever send is USER.
+func (n *internalNegotiator) environ(requested []environVariable) []byte {
+	p := []byte{subIS}
+
+	user := n.ctx.User()
+	if "" == user {
+		return p
+	}
+
+	send := 0 == len(requested)
+	for _, variable := range requested {
+		if environVAR == variable.Type && environUser == variable.Name {
+			send = true
+		}
+	}
+
+	if send {
+		p = appendEnviron(p, environVAR, environUser, user)
+	}
+
+	return p
+}
+
+
+// parseTerminalSpeed parses the "<transmit>,<receive>" format used by TERMINAL-SPEED.
+func parseTerminalSpeed(p []byte) (transmit int, receive int, ok bool) {
+	i := bytes.IndexByte(p, ',')
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	var err error
+
+	transmit, err = strconv.Atoi(string(p[:i]))
+	if nil != err {
+		return 0, 0, false
+	}
+
+	receive, err = strconv.Atoi(string(p[i+1:]))
+	if nil != err {
+		return 0, 0, false
+	}
+
+	return transmit, receive, true
+}
+
+
+// command sends IAC 'command' 'option'.
+func (n *internalNegotiator) command(command byte, option byte) error {
+	return n.write([]byte{cmdIAC, command, option})
+}
+
+
+// sendCommand sends IAC 'command'. (For the commands that do not have an option; such as
+// BRK, IP, AYT and AO.)
+func (n *internalNegotiator) sendCommand(command byte) error {
+	return n.write([]byte{cmdIAC, command})
+}
+
+
+// subnegotiation sends IAC SB 'option' 'data' IAC SE, where any IAC in 'data' gets escaped.
+func (n *internalNegotiator) subnegotiation(option byte, data []byte) error {
+	var buffer bytes.Buffer
+
+	buffer.Write([]byte{cmdIAC, cmdSB, option})
+	for _, datum := range data {
+		if cmdIAC == datum {
+			buffer.WriteByte(cmdIAC)
+		}
+		buffer.WriteByte(datum)
+	}
+	buffer.Write([]byte{cmdIAC, cmdSE})
+
+	return n.write(buffer.Bytes())
+}
+
+
+// write sends 'p' (a command) to the other side. Once this side has half-closed the connection,
+// replies to the other side's requests can no longer be sent; so 'p' is dropped, rather than
+// failing (and making Read fail with it).
+func (n *internalNegotiator) write(p []byte) error {
+	select {
+	case <-n.writeClosed:
+		return nil
+	default:
+	}
+
+	_, err := oi.LongWrite(n.wrapped, p)
+	return err
+}
+
+
+// closeWrite records that this side has half-closed the connection.
+func (n *internalNegotiator) closeWrite() {
+	n.writeClosedOnce.Do(func() {
+		close(n.writeClosed)
+	})
+}
@@ -0,0 +1,550 @@
+package telnet
+
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"testing"
+)
+
+
+func TestNegotiatorServer(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Expected []byte
+
+		ExpectedTerminalType     string
+		ExpectedWidth            int
+		ExpectedHeight           int
+		ExpectedTransmitSpeed    int
+		ExpectedReceiveSpeed     int
+		ExpectedXDisplayLocation string
+		ExpectedLocation         string
+		ExpectedUser             string
+	}{
+		{
+			Bytes:    []byte{255,251,32}, // IAC WILL TERMINAL-SPEED
+			Expected: []byte{255,253,32,   255,250,32,1,255,240}, // IAC DO TERMINAL-SPEED IAC SB TERMINAL-SPEED SEND IAC SE
+		},
+		{
+			Bytes:    []byte{255,251,32,   255,250,32,0,'3','8','4','0','0',',','1','9','2','0','0',255,240}, // IAC WILL TERMINAL-SPEED IAC SB TERMINAL-SPEED IS "38400,19200" IAC SE
+			Expected: []byte{255,253,32,   255,250,32,1,255,240},
+			ExpectedTransmitSpeed: 38400,
+			ExpectedReceiveSpeed:  19200,
+		},
+		{
+			Bytes:    []byte{255,250,32,0,'3','8','4','0','0',',','1','9','2','0','0',255,240}, // IAC SB TERMINAL-SPEED IS "38400,19200" IAC SE (without WILL)
+			Expected: []byte{},
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,35,   255,250,35,0,'h','o','s','t',':','0','.','0',255,240}, // IAC WILL X-DISPLAY-LOCATION IAC SB X-DISPLAY-LOCATION IS "host:0.0" IAC SE
+			Expected: []byte{255,253,35,   255,250,35,1,255,240},
+			ExpectedXDisplayLocation: "host:0.0",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,23,   255,250,23,'R','o','o','m',' ','4',255,240}, // IAC WILL SEND-LOCATION IAC SB SEND-LOCATION "Room 4" IAC SE
+			Expected: []byte{255,253,23},
+			ExpectedLocation: "Room 4",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,39,   255,250,39,0,0,'U','S','E','R',1,'j','o','e',2,1,255,240}, // IAC WILL NEW-ENVIRON IAC SB NEW-ENVIRON IS VAR "USER" VALUE "joe" ESC VALUE IAC SE
+			Expected: []byte{255,253,39,   255,250,39,1,0,'U','S','E','R',255,240}, // IAC DO NEW-ENVIRON IAC SB NEW-ENVIRON SEND VAR "USER" IAC SE
+			ExpectedUser: "joe\x01",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,33}, // IAC WILL TOGGLE-FLOW-CONTROL
+			Expected: []byte{255,253,33},
+		},
+		{
+			Bytes:    []byte{255,251,33,   255,252,33}, // IAC WILL TOGGLE-FLOW-CONTROL IAC WON'T TOGGLE-FLOW-CONTROL
+			Expected: []byte{255,253,33,   255,254,33},
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,24}, // IAC WILL TERMINAL-TYPE
+			Expected: []byte{255,253,24,   255,250,24,1,255,240}, // IAC DO TERMINAL-TYPE IAC SB TERMINAL-TYPE SEND IAC SE
+		},
+		{
+			Bytes:    []byte{255,251,24,   255,250,24,0,'X','T','E','R','M',255,240}, // IAC WILL TERMINAL-TYPE IAC SB TERMINAL-TYPE IS "XTERM" IAC SE
+			Expected: []byte{255,253,24,   255,250,24,1,255,240},
+			ExpectedTerminalType: "XTERM",
+		},
+		{
+			Bytes:    []byte{255,251,31,   255,250,31,0,80,1,255,255,255,240}, // IAC WILL NAWS IAC SB NAWS 80 511 IAC SE
+			Expected: []byte{255,253,31},
+			ExpectedWidth:  80,
+			ExpectedHeight: 511,
+		},
+		{
+			Bytes:    []byte{255,251,31,   255,250,31,0,80,255,240}, // IAC WILL NAWS IAC SB NAWS (too short) IAC SE
+			Expected: []byte{255,253,31},
+		},
+		{
+			Bytes:    []byte{255,253,32}, // IAC DO TERMINAL-SPEED
+			Expected: []byte{255,252,32},
+		},
+		{
+			Bytes:    []byte{255,252,32}, // IAC WON'T TERMINAL-SPEED
+			Expected: []byte{},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		ctx := newContext()
+		negotiator := newNegotiator(&buffer, ctx, true)
+		reader := newNegotiatingDataReader(bytes.NewReader(test.Bytes), negotiator)
+
+		p := make([]byte, 1)
+		n, err := reader.Read(p)
+		if io.EOF != err {
+			t.Errorf("For test #%d, expected io.EOF, but actually got: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := 0, n; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedTerminalType, ctx.TerminalType(); expected != actual {
+			t.Errorf("For test #%d, expected terminal type %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		width, height := ctx.WindowSize()
+		if expected, actual := test.ExpectedWidth, width; expected != actual {
+			t.Errorf("For test #%d, expected width %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := test.ExpectedHeight, height; expected != actual {
+			t.Errorf("For test #%d, expected height %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		transmit, receive := ctx.TerminalSpeed()
+		if expected, actual := test.ExpectedTransmitSpeed, transmit; expected != actual {
+			t.Errorf("For test #%d, expected transmit speed %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := test.ExpectedReceiveSpeed, receive; expected != actual {
+			t.Errorf("For test #%d, expected receive speed %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedXDisplayLocation, ctx.XDisplayLocation(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedLocation, ctx.Location(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedUser, ctx.User(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestNegotiatorClient(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Expected []byte
+
+		TerminalType     string
+		Width            int
+		Height           int
+		TransmitSpeed    int
+		ReceiveSpeed     int
+		XDisplayLocation string
+		Location         string
+		User             string
+		FlowControl      bool
+	}{
+		{
+			Bytes:    []byte{255,253,0}, // IAC DO TRANSMIT-BINARY
+			Expected: []byte{255,252,0}, // IAC WON'T TRANSMIT-BINARY
+		},
+		{
+			Bytes:    []byte{255,251,0}, // IAC WILL TRANSMIT-BINARY
+			Expected: []byte{255,254,0}, // IAC DON'T TRANSMIT-BINARY
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,24}, // IAC DO TERMINAL-TYPE
+			Expected: []byte{255,252,24}, // IAC WON'T TERMINAL-TYPE
+		},
+		{
+			Bytes:    []byte{255,253,24,   255,250,24,1,255,240}, // IAC DO TERMINAL-TYPE IAC SB TERMINAL-TYPE SEND IAC SE
+			Expected: []byte{255,251,24,   255,250,24,0,'x','t','e','r','m',255,240},
+			TerminalType: "xterm",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,31}, // IAC DO NAWS
+			Expected: []byte{255,252,31}, // IAC WON'T NAWS
+		},
+		{
+			Bytes:    []byte{255,253,31}, // IAC DO NAWS
+			Expected: []byte{255,251,31,   255,250,31,0,80,0,24,255,240}, // IAC WILL NAWS IAC SB NAWS 0 80 0 24 IAC SE
+			Width:  80,
+			Height: 24,
+		},
+		{
+			Bytes:    []byte{255,253,31}, // IAC DO NAWS
+			Expected: []byte{255,251,31,   255,250,31,1,255,255,0,255,255,255,240}, // IAC WILL NAWS IAC SB NAWS 1 255 255 0 255 255 IAC SE
+			Width:  511,
+			Height: 255,
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,32}, // IAC DO TERMINAL-SPEED
+			Expected: []byte{255,252,32}, // IAC WON'T TERMINAL-SPEED
+		},
+		{
+			Bytes:    []byte{255,253,32,   255,250,32,1,255,240}, // IAC DO TERMINAL-SPEED IAC SB TERMINAL-SPEED SEND IAC SE
+			Expected: []byte{255,251,32,   255,250,32,0,'9','6','0','0',',','4','8','0','0',255,240},
+			TransmitSpeed: 9600,
+			ReceiveSpeed:  4800,
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,35,   255,250,35,1,255,240}, // IAC DO X-DISPLAY-LOCATION IAC SB X-DISPLAY-LOCATION SEND IAC SE
+			Expected: []byte{255,251,35,   255,250,35,0,'h','o','s','t',':','1',255,240},
+			XDisplayLocation: "host:1",
+		},
+		{
+			Bytes:    []byte{255,253,35}, // IAC DO X-DISPLAY-LOCATION
+			Expected: []byte{255,252,35},
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,23}, // IAC DO SEND-LOCATION
+			Expected: []byte{255,251,23,   255,250,23,'L','a','b',255,240},
+			Location: "Lab",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,39}, // IAC DO NEW-ENVIRON
+			Expected: []byte{255,252,39}, // IAC WON'T NEW-ENVIRON
+		},
+		{
+			Bytes:    []byte{255,253,39,   255,250,39,1,0,'U','S','E','R',255,240}, // IAC DO NEW-ENVIRON IAC SB NEW-ENVIRON SEND VAR "USER" IAC SE
+			Expected: []byte{255,251,39,   255,250,39,0,0,'U','S','E','R',1,'j','o','e',255,240},
+			User: "joe",
+		},
+		{
+			Bytes:    []byte{255,253,39,   255,250,39,1,0,'T','E','R','M',255,240}, // IAC DO NEW-ENVIRON IAC SB NEW-ENVIRON SEND VAR "TERM" IAC SE
+			Expected: []byte{255,251,39,   255,250,39,0,255,240},
+			User: "joe",
+		},
+
+
+
+		{
+			Bytes:    []byte{255,253,33}, // IAC DO TOGGLE-FLOW-CONTROL
+			Expected: []byte{255,252,33}, // IAC WON'T TOGGLE-FLOW-CONTROL
+		},
+		{
+			Bytes:    []byte{255,253,33,   255,250,33,2,255,240}, // IAC DO TOGGLE-FLOW-CONTROL IAC SB TOGGLE-FLOW-CONTROL RESTART-ANY IAC SE
+			Expected: []byte{255,251,33},
+			FlowControl: true,
+		},
+		{
+			Bytes:    []byte{255,251,33}, // IAC WILL TOGGLE-FLOW-CONTROL
+			Expected: []byte{255,254,33},
+		},
+
+
+
+		{
+			Bytes:    []byte{255,251,1,   255,251,3}, // IAC WILL ECHO IAC WILL SUPPRESS-GO-AHEAD
+			Expected: []byte{255,253,1,   255,253,3},
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		ctx := newContext()
+		ctx.InjectTerminalType(test.TerminalType)
+		ctx.InjectWindowSize(test.Width, test.Height)
+		ctx.InjectTerminalSpeed(test.TransmitSpeed, test.ReceiveSpeed)
+		ctx.InjectXDisplayLocation(test.XDisplayLocation)
+		ctx.InjectLocation(test.Location)
+		ctx.InjectUser(test.User)
+
+		negotiator := newNegotiator(&buffer, ctx, false)
+		if test.FlowControl {
+			negotiator.acceptFlowControl()
+		}
+		reader := newNegotiatingDataReader(bytes.NewReader(test.Bytes), negotiator)
+
+		p := make([]byte, 1)
+		if _, err := reader.Read(p); io.EOF != err {
+			t.Errorf("For test #%d, expected io.EOF, but actually got: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestNegotiatorWindowSize(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	ctx := newContext()
+	negotiator := newNegotiator(&buffer, ctx, false)
+
+	if err := negotiator.sendWindowSize(); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		return
+	}
+	if expected, actual := "", buffer.String(); expected != actual {
+		t.Errorf("Expected %q (since NAWS is not enabled), but actually got %q.", expected, actual)
+		return
+	}
+
+	ctx.InjectWindowSize(80, 24)
+	if err := negotiator.offerLocal(optionWindowSize); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		return
+	}
+	reader := newNegotiatingDataReader(bytes.NewReader([]byte{255,253,31}), negotiator) // IAC DO NAWS
+	if _, err := reader.Read(make([]byte, 1)); io.EOF != err {
+		t.Errorf("Expected io.EOF, but actually got: (%T) %v", err, err)
+		return
+	}
+	if expected, actual := "\xff\xfb\x1f\xff\xfa\x1f\x00P\x00\x18\xff\xf0", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		return
+	}
+
+	buffer.Reset()
+	ctx.InjectWindowSize(132, 43)
+	if err := negotiator.sendWindowSize(); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		return
+	}
+	if expected, actual := "\xff\xfa\x1f\x00\x84\x00+\xff\xf0", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		return
+	}
+}
+
+
+func TestNegotiatorBinary(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	negotiator := newNegotiator(&buffer, newContext(), false)
+
+	if err := negotiator.requestBinary(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "\xff\xfb\x00\xff\xfd\x00", buffer.String(); expected != actual { // IAC WILL TRANSMIT-BINARY IAC DO TRANSMIT-BINARY
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	buffer.Reset()
+	reader := newNegotiatingDataReader(bytes.NewReader([]byte{255,253,0,   255,251,0}), negotiator) // IAC DO TRANSMIT-BINARY IAC WILL TRANSMIT-BINARY
+	if _, err := reader.Read(make([]byte, 1)); io.EOF != err {
+		t.Fatalf("Expected io.EOF, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := "", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if !negotiator.localEnabled(optionBinary) || !negotiator.remoteEnabled(optionBinary) {
+		t.Errorf("Expected TRANSMIT-BINARY to be enabled in both directions, but it was not.")
+	}
+}
+
+
+func TestNegotiatorFlowControl(t *testing.T) {
+
+	tests := []struct{
+		Negotiation []byte
+		Binary      bool
+		NoAccept    bool
+		Data        []byte
+		Expected    []byte
+		Stopped     bool
+	}{
+		{
+			Negotiation: []byte{255,253,33}, // IAC DO TOGGLE-FLOW-CONTROL
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b'
+			Expected:    []byte("ab"),
+			Stopped:     true,
+		},
+		{
+			Negotiation: []byte{255,253,33}, // IAC DO TOGGLE-FLOW-CONTROL
+			Data:        []byte{'a',0x13,'b',0x11,'c'}, // 'a' XOFF 'b' XON 'c'
+			Expected:    []byte("abc"),
+			Stopped:     false,
+		},
+		{
+			Negotiation: []byte{255,253,33,   255,250,33,2,255,240}, // IAC DO TOGGLE-FLOW-CONTROL IAC SB TOGGLE-FLOW-CONTROL RESTART-ANY IAC SE
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b'
+			Expected:    []byte("ab"),
+			Stopped:     false,
+		},
+		{
+			Negotiation: []byte{255,253,33,   255,250,33,0,255,240}, // IAC DO TOGGLE-FLOW-CONTROL IAC SB TOGGLE-FLOW-CONTROL OFF IAC SE
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b'
+			Expected:    []byte("a\x13b"),
+			Stopped:     false,
+		},
+		{
+			Negotiation: []byte{255,253,33,   255,250,33,0,255,240,   255,250,33,1,255,240}, // ... OFF IAC SE IAC SB TOGGLE-FLOW-CONTROL ON IAC SE
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b'
+			Expected:    []byte("ab"),
+			Stopped:     true,
+		},
+		{
+			Negotiation: []byte{},
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b' (without TOGGLE-FLOW-CONTROL)
+			Expected:    []byte("a\x13b"),
+			Stopped:     false,
+		},
+		{
+			Negotiation: []byte{255,253,33}, // IAC DO TOGGLE-FLOW-CONTROL
+			NoAccept:    true,
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b' (TOGGLE-FLOW-CONTROL was refused)
+			Expected:    []byte("a\x13b"),
+			Stopped:     false,
+		},
+		{
+			Negotiation: []byte{255,253,33,   255,253,0}, // IAC DO TOGGLE-FLOW-CONTROL IAC DO TRANSMIT-BINARY
+			Binary:      true,
+			Data:        []byte{'a',0x13,'b'}, // 'a' XOFF 'b' (in binary mode)
+			Expected:    []byte("a\x13b"),
+			Stopped:     false,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		negotiator := newNegotiator(&buffer, newContext(), false)
+		if !test.NoAccept {
+			negotiator.acceptFlowControl()
+		}
+		if test.Binary {
+			negotiator.requestBinary()
+		}
+		reader := newNegotiatingDataReader(bytes.NewReader(test.Negotiation), negotiator)
+		if _, err := reader.Read(make([]byte, 1)); io.EOF != err {
+			t.Errorf("For test #%d, expected io.EOF, but actually got: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		var output bytes.Buffer
+		writer := newNegotiatingDataWriter(&output, negotiator)
+
+		n, err := writer.Write(test.Data)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := len(test.Data), n; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), output.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		err = negotiator.flow.wait(time.Now().Add(20 * time.Millisecond))
+		if expected, actual := test.Stopped, os.ErrDeadlineExceeded == err; expected != actual {
+			t.Errorf("For test #%d, expected output to be stopped to be %t, but actually got %t. (Error: %v)", testNumber, expected, actual, err)
+			continue
+		}
+	}
+}
+
+
+func TestNegotiatorSetFlowControl(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	negotiator := newNegotiator(&buffer, newContext(), true)
+
+	if err := negotiator.setFlowControl(true, true); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := string([]byte{255,253,33}), buffer.String(); expected != actual { // IAC DO TOGGLE-FLOW-CONTROL
+		t.Fatalf("Expected %q, but actually got %q.", expected, actual)
+	}
+	buffer.Reset()
+
+	if err := negotiator.negotiate(cmdWILL, optionToggleFlowControl); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	// IAC SB TOGGLE-FLOW-CONTROL ON IAC SE IAC SB TOGGLE-FLOW-CONTROL RESTART-ANY IAC SE
+	if expected, actual := string([]byte{255,250,33,1,255,240,   255,250,33,2,255,240}), buffer.String(); expected != actual {
+		t.Fatalf("Expected %q, but actually got %q.", expected, actual)
+	}
+	buffer.Reset()
+
+	if err := negotiator.setFlowControl(false, false); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	// IAC SB TOGGLE-FLOW-CONTROL OFF IAC SE IAC SB TOGGLE-FLOW-CONTROL RESTART-XON IAC SE
+	if expected, actual := string([]byte{255,250,33,0,255,240,   255,250,33,3,255,240}), buffer.String(); expected != actual {
+		t.Fatalf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,100 @@
+package telnet
+
+
+import (
+	"bytes"
+)
+
+
+// environUser is the NEW-ENVIRON (RFC 1572) well-known variable for the user name.
+const environUser = "USER"
+
+
+// An environVariable is a variable from a NEW-ENVIRON (RFC 1572) subnegotiation.
+type environVariable struct {
+	Type     byte // environVAR or environUSERVAR.
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+
+// parseEnviron parses the list of variables in a NEW-ENVIRON IS, INFO or SEND subnegotiation.
+// ('p' is what comes after the IS, INFO or SEND.)
+//
+// For a SEND, the variables have no values.
+func parseEnviron(p []byte) []environVariable {
+
+	var variables []environVariable
+
+	var current *environVariable
+	var buffer bytes.Buffer
+	inValue := false
+
+	finish := func() {
+		if nil == current {
+			return
+		}
+
+		if inValue {
+			current.Value = buffer.String()
+		} else {
+			current.Name = buffer.String()
+		}
+		variables = append(variables, *current)
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch b := p[i]; b {
+		case environVAR, environUSERVAR:
+			finish()
+			current = &environVariable{Type:b}
+			buffer.Reset()
+			inValue = false
+		case environVALUE:
+			if nil == current || inValue {
+				continue
+			}
+			current.Name = buffer.String()
+			current.HasValue = true
+			buffer.Reset()
+			inValue = true
+		case environESC:
+			if i+1 < len(p) {
+				i++
+				buffer.WriteByte(p[i])
+			}
+		default:
+			buffer.WriteByte(b)
+		}
+	}
+	finish()
+
+	return variables
+}
+
+
+// appendEnviron appends a NEW-ENVIRON variable, of type 'typ', with 'name' and 'value', to 'p',
+// escaping (with ESC) any bytes that need it.
+func appendEnviron(p []byte, typ byte, name string, value string) []byte {
+	p = append(p, typ)
+	p = appendEnvironEscaped(p, name)
+	p = append(p, environVALUE)
+	p = appendEnvironEscaped(p, value)
+
+	return p
+}
+
+
+func appendEnvironEscaped(p []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; b {
+		case environVAR, environVALUE, environESC, environUSERVAR:
+			p = append(p, environESC, b)
+		default:
+			p = append(p, b)
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,45 @@
+package telnet
+
+
+// TELNET (and TELNETS) option codes.
+//
+// These are the bytes that come after a WILL, WON'T, DO, DON'T, or SB command.
+const (
+	optionBinary            =  0 // RFC 856 (TRANSMIT-BINARY)
+	optionEcho              =  1 // RFC 857
+	optionSuppressGoAhead   =  3 // RFC 858
+	optionSendLocation      = 23 // RFC 779
+	optionTerminalType      = 24 // RFC 1091
+	optionEndOfRecord       = 25 // RFC 885
+	optionWindowSize        = 31 // RFC 1073 (NAWS)
+	optionTerminalSpeed     = 32 // RFC 1079
+	optionToggleFlowControl = 33 // RFC 1372
+	optionXDisplayLocation  = 35 // RFC 1096
+	optionNewEnviron        = 39 // RFC 1572
+)
+
+
+// Subnegotiation qualifiers used by TERMINAL-TYPE, TERMINAL-SPEED, X-DISPLAY-LOCATION, NEW-ENVIRON (and others).
+const (
+	subIS   = 0
+	subSEND = 1
+	subINFO = 2
+)
+
+
+// Subnegotiation commands used by TOGGLE-FLOW-CONTROL.
+const (
+	lflowOFF        = 0
+	lflowON         = 1
+	lflowRESTARTANY = 2
+	lflowRESTARTXON = 3
+)
+
+
+// Subnegotiation type codes used by NEW-ENVIRON.
+const (
+	environVAR     = 0
+	environVALUE   = 1
+	environESC     = 2
+	environUSERVAR = 3
+)
@@ -0,0 +1,36 @@
+package telnet
+
+
+import (
+	"fmt"
+)
+
+
+// A ProtocolError is returned (by Read) when the TELNET (or TELNETS) data received does
+// not follow the TELNET protocol. For example, when an IAC is followed by a byte that is
+// not a TELNET command. Or when a subnegotiation (IAC SB) goes on for more than 4 KiB without
+// being ended (with an IAC SE).
+//
+// It can be told apart from I/O errors with errors.As. For example:
+//
+//	var protocolError *telnet.ProtocolError
+//	if errors.As(err, &protocolError) {
+//		//@TODO: Deal with the protocol error.
+//	}
+//
+// (Rather than getting a ProtocolError, a Server or Client can instead be made lenient.
+// In which case, the problem is logged and the bad sequence is skipped over.)
+type ProtocolError struct {
+	Command     byte   // The byte that came after the IAC. (SB, if a subnegotiation was too long.)
+	Offset      int64  // The offset, in the received stream, of the IAC.
+	Surrounding []byte // The bytes received just before, and from, the IAC.
+}
+
+
+func (err *ProtocolError) Error() string {
+	if cmdSB == err.Command {
+		return fmt.Sprintf("Corrupted: subnegotiation (IAC SB) at offset %d not ended within %d bytes (starting with: %q)", err.Offset, maxSubnegotiationSize, err.Surrounding)
+	}
+
+	return fmt.Sprintf("Corrupted: IAC followed by non-command byte %d at offset %d (surrounding bytes: %q)", err.Command, err.Offset, err.Surrounding)
+}
@@ -0,0 +1,152 @@
+package telnet
+
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"testing"
+)
+
+
+func TestProtocolError(t *testing.T) {
+
+	tests := []struct{
+		Bytes               []byte
+		ExpectedData        []byte
+		ExpectedCommand     byte
+		ExpectedOffset      int64
+		ExpectedSurrounding []byte
+	}{
+		{
+			Bytes:               []byte{255,1},
+			ExpectedData:        []byte{},
+			ExpectedCommand:     1,
+			ExpectedOffset:      0,
+			ExpectedSurrounding: []byte{255,1},
+		},
+		{
+			Bytes:               []byte("apple\xff\x07banana"),
+			ExpectedData:        []byte("apple"),
+			ExpectedCommand:     7,
+			ExpectedOffset:      5,
+			ExpectedSurrounding: []byte("apple\xff\x07banana"[:5+8]),
+		},
+		{
+			Bytes:               []byte("0123456789\xff\xff\xff\x00"),
+			ExpectedData:        []byte("0123456789\xff"),
+			ExpectedCommand:     0,
+			ExpectedOffset:      12,
+			ExpectedSurrounding: []byte("456789\xff\xff\xff\x00"),
+		},
+		{
+			Bytes:               append([]byte("ab\xff\xfa\x18"), bytes.Repeat([]byte("x"), 5000)...), // 'a' 'b' IAC SB TERMINAL-TYPE "xxx..." (without an IAC SE)
+			ExpectedData:        []byte("ab"),
+			ExpectedCommand:     250,
+			ExpectedOffset:      2,
+			ExpectedSurrounding: []byte("\xff\xfa\x18xxxxx"),
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		reader := newDataReader(bytes.NewReader(test.Bytes))
+
+		var data []byte
+		var err error
+
+		buffer := make([]byte, 64)
+		for {
+			var n int
+			n, err = reader.Read(buffer)
+			data = append(data, buffer[:n]...)
+			if nil != err {
+				break
+			}
+		}
+
+		var protocolError *ProtocolError
+		if !errors.As(err, &protocolError) {
+			t.Errorf("For test #%d, expected a *ProtocolError, but actually got: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := string(test.ExpectedData), string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedCommand, protocolError.Command; expected != actual {
+			t.Errorf("For test #%d, expected command %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.ExpectedOffset, protocolError.Offset; expected != actual {
+			t.Errorf("For test #%d, expected offset %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := string(test.ExpectedSurrounding), string(protocolError.Surrounding); expected != actual {
+			t.Errorf("For test #%d, expected surrounding bytes %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestProtocolErrorLenient(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Expected []byte
+	}{
+		{
+			Bytes:    []byte{255,1},
+			Expected: []byte{},
+		},
+		{
+			Bytes:    []byte("apple\xff\x07banana"),
+			Expected: []byte("applebanana"),
+		},
+		{
+			Bytes:    []byte("a\xff\x00b\xff\xffc\xff\x10\xff\xfb\x18d"), // 'a' IAC 0 'b' IAC IAC 'c' IAC 16 IAC WILL TERMINAL-TYPE 'd'
+			Expected: []byte("ab\xffcd"),
+		},
+		{
+			Bytes:    append(append([]byte("a\xff\xfa\x18"), bytes.Repeat([]byte("x"), 5000)...), "\xff\xf0b"...), // 'a' IAC SB TERMINAL-TYPE "xxx..." IAC SE 'b'
+			Expected: []byte("ab"),
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		reader := newDataReader(bytes.NewReader(test.Bytes))
+		reader.lenient = true
+
+		var data []byte
+		var err error
+
+		buffer := make([]byte, 64)
+		for {
+			var n int
+			n, err = reader.Read(buffer)
+			data = append(data, buffer[:n]...)
+			if nil != err {
+				break
+			}
+		}
+
+		if io.EOF != err {
+			t.Errorf("For test #%d, expected io.EOF, but actually got: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
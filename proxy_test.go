@@ -0,0 +1,315 @@
+package telnet
+
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"testing"
+)
+
+
+// serveSOCKS5 is a (minimal) stand-in SOCKS5 proxy, that requires the username "joe" and
+// the password "secret". (Given the password "version", it replies to the username and password
+// with the wrong version.)
+func serveSOCKS5(t *testing.T, listener net.Listener) {
+	for {
+		client, err := listener.Accept()
+		if nil != err {
+			return
+		}
+
+		go func() {
+			defer client.Close()
+
+			var greeting [3]byte
+			if _, err := io.ReadFull(client, greeting[:]); nil != err || 0x02 != greeting[2] {
+				client.Write([]byte{0x05, 0xFF})
+				return
+			}
+			client.Write([]byte{0x05, 0x02})
+
+			var header [2]byte
+			io.ReadFull(client, header[:])
+			username := make([]byte, header[1])
+			io.ReadFull(client, username)
+			io.ReadFull(client, header[:1])
+			password := make([]byte, header[0])
+			io.ReadFull(client, password)
+			if "version" == string(password) {
+				client.Write([]byte{0x05, 0x00})
+				return
+			}
+			if "joe" != string(username) || "secret" != string(password) {
+				client.Write([]byte{0x01, 0x01})
+				return
+			}
+			client.Write([]byte{0x01, 0x00})
+
+			var request [4]byte
+			io.ReadFull(client, request[:])
+
+			var host string
+			switch request[3] {
+			case 0x01:
+				ip := make([]byte, 4)
+				io.ReadFull(client, ip)
+				host = net.IP(ip).String()
+			case 0x03:
+				io.ReadFull(client, header[:1])
+				name := make([]byte, header[0])
+				io.ReadFull(client, name)
+				host = string(name)
+			}
+			var port [2]byte
+			io.ReadFull(client, port[:])
+
+			target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+			if nil != err {
+				client.Write([]byte{0x05, 0x05, 0x00, 0x01, 0,0,0,0, 0,0})
+				return
+			}
+			defer target.Close()
+
+			client.Write([]byte{0x05, 0x00, 0x00, 0x01, 127,0,0,1, 0,0})
+
+			go io.Copy(target, client)
+			io.Copy(client, target)
+		}()
+	}
+}
+
+
+// serveHTTPConnect is a (minimal) stand-in HTTP CONNECT proxy.
+func serveHTTPConnect(t *testing.T, listener net.Listener) {
+	for {
+		client, err := listener.Accept()
+		if nil != err {
+			return
+		}
+
+		go func() {
+			defer client.Close()
+
+			request, err := http.ReadRequest(bufio.NewReader(client))
+			if nil != err || "CONNECT" != request.Method {
+				return
+			}
+
+			target, err := net.Dial("tcp", request.Host)
+			if nil != err {
+				io.WriteString(client, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+				return
+			}
+			defer target.Close()
+
+			io.WriteString(client, "HTTP/1.1 200 Connection established\r\nX-Proxy: test\r\n\r\n")
+
+			go io.Copy(target, client)
+			io.Copy(client, target)
+		}()
+	}
+}
+
+
+func testDialThroughProxy(t *testing.T, serve func(*testing.T, net.Listener), proxy string, addr func(net.Listener) string) {
+
+	echoListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer echoListener.Close()
+	go Serve(echoListener, EchoHandler)
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer proxyListener.Close()
+	go serve(t, proxyListener)
+
+	proxyURL, err := url.Parse(proxy + proxyListener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	dialer := Dialer{
+		Timeout: 5 * time.Second,
+		Proxy:   ProxyURL(proxyURL),
+	}
+
+	conn, err := dialer.Dial("tcp", addr(echoListener))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("apple")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p := make([]byte, len("apple"))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "apple", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+
+func TestDialerSOCKS5(t *testing.T) {
+	testDialThroughProxy(t, serveSOCKS5, "socks5://joe:secret@", func(listener net.Listener) string {
+		return listener.Addr().String()
+	})
+}
+
+
+func TestDialerSOCKS5RemoteResolve(t *testing.T) {
+	testDialThroughProxy(t, serveSOCKS5, "socks5h://joe:secret@", func(listener net.Listener) string {
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		return net.JoinHostPort("localhost", port)
+	})
+}
+
+
+func TestDialerHTTPConnect(t *testing.T) {
+	testDialThroughProxy(t, serveHTTPConnect, "http://", func(listener net.Listener) string {
+		return listener.Addr().String()
+	})
+}
+
+
+func TestDialerSOCKS5AuthFailed(t *testing.T) {
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer proxyListener.Close()
+	go serveSOCKS5(t, proxyListener)
+
+	dialer := Dialer{
+		Proxy: ProxyURL(&url.URL{Scheme:"socks5", User:url.UserPassword("joe", "wrong"), Host:proxyListener.Addr().String()}),
+	}
+
+	if _, err := dialer.Dial("tcp", "127.0.0.1:23"); errSOCKS5AuthFailed != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", errSOCKS5AuthFailed, err, err)
+	}
+}
+
+
+func TestDialerSOCKS5AuthVersion(t *testing.T) {
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer proxyListener.Close()
+	go serveSOCKS5(t, proxyListener)
+
+	dialer := Dialer{
+		Proxy: ProxyURL(&url.URL{Scheme:"socks5", User:url.UserPassword("joe", "version"), Host:proxyListener.Addr().String()}),
+	}
+
+	if _, err := dialer.Dial("tcp", "127.0.0.1:23"); errSOCKS5Version != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", errSOCKS5Version, err, err)
+	}
+}
+
+
+func TestProxyFromEnvironment(t *testing.T) {
+
+	t.Setenv("all_proxy", "")
+	t.Setenv("no_proxy", "")
+
+	tests := []struct{
+		AllProxy string
+		NoProxy  string
+		Addr     string
+		Expected string
+	}{
+		{
+			AllProxy: "",
+			Addr:     "example.net:23",
+			Expected: "",
+		},
+		{
+			AllProxy: "socks5://bastion:1080",
+			Addr:     "example.net:23",
+			Expected: "socks5://bastion:1080",
+		},
+		{
+			AllProxy: "bastion:1080",
+			Addr:     "example.net:23",
+			Expected: "socks5://bastion:1080",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "localhost, .example.net",
+			Addr:     "router.example.net:23",
+			Expected: "",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "example.net",
+			Addr:     "example.net:23",
+			Expected: "",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "example.net",
+			Addr:     "example.com:23",
+			Expected: "http://bastion:3128",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "10.0.0.0/8",
+			Addr:     "10.1.2.3:23",
+			Expected: "",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "::1",
+			Addr:     "[::1]:23",
+			Expected: "",
+		},
+		{
+			AllProxy: "http://bastion:3128",
+			NoProxy:  "*",
+			Addr:     "example.com:23",
+			Expected: "",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		t.Setenv("ALL_PROXY", test.AllProxy)
+		t.Setenv("NO_PROXY", test.NoProxy)
+
+		proxyURL, err := ProxyFromEnvironment(test.Addr)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		var actual string
+		if nil != proxyURL {
+			actual = proxyURL.String()
+		}
+
+		if expected := test.Expected; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package telnet
+
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+
+// openPty opens a new pseudo-terminal, and returns its master and slave sides.
+func openPty() (master *os.File, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	var number uint32
+	err = control(master, func(fd int) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); 0 != errno {
+			return errno
+		}
+
+		var unlock int32
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); 0 != errno {
+			return errno
+		}
+
+		return nil
+	})
+	if nil != err {
+		master.Close()
+		return nil, nil, err
+	}
+
+	slave, err = os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(number), 10), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if nil != err {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+
+// control calls 'f' with the file descriptor of 'file'. (Unlike file.Fd, this does not put 'file'
+// into blocking mode; so Close still interrupts a Read.)
+func control(file *os.File, f func(fd int) error) error {
+	rawConn, err := file.SyscallConn()
+	if nil != err {
+		return err
+	}
+
+	var ferr error
+	if err := rawConn.Control(func(fd uintptr) { ferr = f(int(fd)) }); nil != err {
+		return err
+	}
+
+	return ferr
+}
+
+
+// foregroundProcessGroup returns the process group in the foreground of the terminal 'fd'.
+func foregroundProcessGroup(fd int) (int, error) {
+	var pgrp int32
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); 0 != errno {
+		return 0, errno
+	}
+
+	return int(pgrp), nil
+}
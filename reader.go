@@ -4,3 +4,21 @@ package telnet
 type Reader interface {
 	Read([]byte) (int, error)
 }
+
+
+// A RecordReader is a Reader that can also tell where a record (such as a prompt) ends.
+//
+// A Read stops at the end of a record, (i.e., when it gets to an IAC EOR or an IAC GA), and
+// after that EndOfRecord returns true. If the IAC EOR (or IAC GA) arrives after the data before
+// it was already returned, then a Read returns 0 bytes with EndOfRecord true; i.e., the data
+// before it ended a record. So, for example:
+//
+//	n, err := r.Read(p)
+//	
+//	if recordReader, ok := r.(telnet.RecordReader); ok && recordReader.EndOfRecord() {
+//		// p[:n] (or, if n is 0, what was read before it) ends with a prompt.
+//	}
+type RecordReader interface {
+	Reader
+	EndOfRecord() bool
+}
@@ -0,0 +1,249 @@
+package telnet
+
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+
+var errReconnectGaveUp = errors.New("Gave up reconnecting.")
+
+
+const (
+	defaultReconnectInitialDelay = 1 * time.Second
+	defaultReconnectMaxDelay     = 1 * time.Minute
+	defaultReconnectMultiplier   = 2.0
+	defaultReconnectResetAfter   = 30 * time.Second
+)
+
+
+// A ReconnectPolicy says when, and how often, a Client reconnects after its connection drops.
+//
+// The delay before each attempt starts at InitialDelay, and is multiplied by Multiplier after
+// each failed attempt, up to MaxDelay. Each delay is then randomly adjusted by up to Jitter
+// (as a fraction of it), so that many clients do not all reconnect at the same time.
+//
+// The backoff (along with MaxAttempts and MaxDuration) only starts over once a connection has
+// stayed up for ResetAfter. So a server that accepts connections and then drops them right
+// away is still backed off from (and given up on).
+//
+// For example:
+//
+//	client := telnet.Client{
+//		Caller: caller,
+//		Reconnect: &telnet.ReconnectPolicy{
+//			InitialDelay: 500 * time.Millisecond,
+//			MaxDelay:     30 * time.Second,
+//			Jitter:       0.2,
+//			MaxDuration:  10 * time.Minute,
+//		},
+//	}
+//	
+//	err := client.DialAndCall(ctx, &telnet.Dialer{Timeout: 10 * time.Second}, "tcp", "example.net:23")
+type ReconnectPolicy struct {
+	InitialDelay time.Duration // delay before the first attempt; 1 second if zero.
+	MaxDelay     time.Duration // the most the delay gets backed off to; 1 minute if zero.
+	Multiplier   float64       // how much the delay is multiplied by after each failed attempt; 2 if zero.
+	Jitter       float64       // from 0 to 1; how much (as a fraction) each delay is randomly adjusted by.
+
+	MaxAttempts int           // the most attempts made after a drop (before giving up); no limit if zero.
+	MaxDuration time.Duration // the most time spent trying to reconnect after a drop (before giving up); no limit if zero.
+	ResetAfter  time.Duration // how long a connection has to stay up for the backoff to start over; 30 seconds if zero.
+
+	// ShouldReconnect, if not nil, decides whether to reconnect after a session ended. If nil,
+	// then the Client reconnects unless the Caller finished (SessionEndLocalEOF), the other
+	// side broke the protocol (SessionEndProtocolError), or logging in failed (a *LoginError).
+	ShouldReconnect func(SessionResult) bool
+
+	// OnReconnect, if not nil, is called before waiting to make each attempt. 'attempt' starts
+	// at 1 for each drop, and 'err' is why the previous connection (or attempt) ended. (It
+	// might be nil, if the other side closed the connection.)
+	OnReconnect func(attempt int, delay time.Duration, err error)
+}
+
+
+func (policy *ReconnectPolicy) shouldReconnect(result SessionResult) bool {
+	if nil != policy.ShouldReconnect {
+		return policy.ShouldReconnect(result)
+	}
+
+	// Trying the same credentials again will not help.
+	var loginError *LoginError
+	if errors.As(result.Err, &loginError) {
+		return false
+	}
+
+	switch result.End {
+	case SessionEndLocalEOF, SessionEndProtocolError:
+		return false
+	default:
+		return true
+	}
+}
+
+
+// delay returns how long to wait before attempt number 'attempt'. (Where 'attempt' starts at 1.)
+func (policy *ReconnectPolicy) delay(attempt int) time.Duration {
+
+	initialDelay := policy.InitialDelay
+	if initialDelay <= 0 {
+		initialDelay = defaultReconnectInitialDelay
+	}
+
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultReconnectMultiplier
+	}
+
+	delay := float64(initialDelay)
+	for i := 1; i < attempt && delay < float64(maxDelay); i++ {
+		delay *= multiplier
+	}
+	if float64(maxDelay) < delay {
+		delay = float64(maxDelay)
+	}
+
+	if jitter := policy.Jitter; 0 < jitter {
+		if 1 < jitter {
+			jitter = 1
+		}
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+
+// DialAndCall dials 'addr' on 'network' with 'dialer' (which may be nil), and then runs the
+// Client's Caller over the connection. (See Call.)
+//
+// If the Client has a Reconnect policy, then when the connection drops (or cannot be made),
+// DialAndCall waits and dials again, per the policy, until the Caller finishes, the policy
+// gives up, or 'ctx' is canceled.
+//
+// Canceling 'ctx' also closes the connection, if the Caller is running. (In which case
+// DialAndCall returns ctx.Err().)
+func (client *Client) DialAndCall(ctx context.Context, dialer *Dialer, network string, addr string) error {
+
+	if nil == dialer {
+		dialer = &Dialer{}
+	}
+
+	logger := client.logger()
+	policy := client.Reconnect
+
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if nil == policy {
+		if nil != err {
+			return err
+		}
+
+		result := client.callSessionContext(ctx, conn)
+		if nil != ctx.Err() {
+			return ctx.Err()
+		}
+
+		return result.Err
+	}
+
+	resetAfter := policy.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = defaultReconnectResetAfter
+	}
+
+	dropped := time.Now()
+	attempt := 0
+
+	for {
+		if nil == err {
+			connected := time.Now()
+
+			result := client.callSessionContext(ctx, conn)
+			if nil != ctx.Err() {
+				return ctx.Err()
+			}
+			if !policy.shouldReconnect(result) {
+				return result.Err
+			}
+
+			logger.Debugf("Connection to %q dropped: %v (%v)", addr, result.End, result.Err)
+
+			err = result.Err
+
+			// Only start the backoff over if the connection stayed up for a while. (Else,
+			// this is still the same drop.)
+			if resetAfter <= time.Since(connected) {
+				dropped = time.Now()
+				attempt = 0
+			}
+		}
+
+		attempt++
+		if 0 < policy.MaxAttempts && policy.MaxAttempts < attempt {
+			return reconnectGaveUp(err)
+		}
+
+		delay := policy.delay(attempt)
+		if 0 < policy.MaxDuration && policy.MaxDuration < time.Since(dropped) + delay {
+			return reconnectGaveUp(err)
+		}
+
+		if nil != policy.OnReconnect {
+			policy.OnReconnect(attempt, delay, err)
+		}
+		logger.Debugf("Reconnecting to %q in %v (attempt %d).", addr, delay, attempt)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		conn, err = dialer.DialContext(ctx, network, addr)
+	}
+}
+
+
+// callSessionContext is CallSession; but canceling 'ctx' closes 'conn'. (Which makes the
+// Caller stop.)
+func (client *Client) callSessionContext(ctx context.Context, conn *Conn) SessionResult {
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	result := client.CallSession(conn)
+
+	close(done)
+	<-watched
+
+	return result
+}
+
+
+// reconnectGaveUp returns the error to return when giving up on reconnecting; i.e., the
+// last error, if there was one.
+func reconnectGaveUp(err error) error {
+	if nil == err {
+		return errReconnectGaveUp
+	}
+
+	return err
+}
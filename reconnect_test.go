@@ -0,0 +1,259 @@
+package telnet
+
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"testing"
+)
+
+
+func TestReconnectPolicyDelay(t *testing.T) {
+
+	policy := ReconnectPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     1 * time.Second,
+	}
+
+	tests := []struct{
+		Attempt  int
+		Expected time.Duration
+	}{
+		{Attempt: 1, Expected:  100 * time.Millisecond},
+		{Attempt: 2, Expected:  200 * time.Millisecond},
+		{Attempt: 3, Expected:  400 * time.Millisecond},
+		{Attempt: 4, Expected:  800 * time.Millisecond},
+		{Attempt: 5, Expected: 1000 * time.Millisecond},
+		{Attempt:50, Expected: 1000 * time.Millisecond},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, policy.delay(test.Attempt); expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			continue
+		}
+	}
+
+
+	policy.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1)
+		if delay < 50 * time.Millisecond || 150 * time.Millisecond < delay {
+			t.Errorf("Expected a delay between 50ms and 150ms, but actually got %v.", delay)
+			break
+		}
+	}
+}
+
+
+func TestClientReconnect(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	// The server drops each connection right away.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			conn.Write([]byte("Hello"))
+			conn.Close()
+		}
+	}()
+
+	var connects, calls, reconnects int
+
+	client := Client{
+		OnConnect: func(ctx Context, w Writer, r Reader) error {
+			connects++
+			return nil
+		},
+		Caller: CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			calls++
+			if 3 <= calls {
+				// Done; i.e., SessionEndLocalEOF.
+				return nil
+			}
+
+			_, err := ioutil.ReadAll(r)
+			return err
+		}),
+		Reconnect: &ReconnectPolicy{
+			InitialDelay: time.Millisecond,
+			OnReconnect: func(attempt int, delay time.Duration, err error) {
+				reconnects++
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+
+	if err := client.DialAndCall(ctx, nil, "tcp", listener.Addr().String()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := 3, connects; expected != actual {
+		t.Errorf("Expected OnConnect to be called %d times, but actually was called %d times.", expected, actual)
+	}
+	if expected, actual := 3, calls; expected != actual {
+		t.Errorf("Expected the Caller to be called %d times, but actually was called %d times.", expected, actual)
+	}
+	if expected, actual := 2, reconnects; expected != actual {
+		t.Errorf("Expected OnReconnect to be called %d times, but actually was called %d times.", expected, actual)
+	}
+}
+
+
+func TestClientReconnectGivesUp(t *testing.T) {
+
+	// Get an address that nothing is listening on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var attempts []int
+
+	client := Client{
+		Caller: CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			t.Errorf("Did not expect the Caller to be called.")
+			return nil
+		}),
+		Reconnect: &ReconnectPolicy{
+			InitialDelay: time.Millisecond,
+			MaxAttempts:  3,
+			OnReconnect: func(attempt int, delay time.Duration, err error) {
+				attempts = append(attempts, attempt)
+			},
+		},
+	}
+
+	err = client.DialAndCall(context.Background(), nil, "tcp", addr)
+	if nil == err {
+		t.Fatalf("Expected an error, but did not actually get one.")
+	}
+
+	if expected, actual := 3, len(attempts); expected != actual {
+		t.Fatalf("Expected %d attempts, but actually got %d: %v", expected, actual, attempts)
+	}
+	for i, attempt := range attempts {
+		if expected, actual := i+1, attempt; expected != actual {
+			t.Errorf("For attempt #%d, expected %d, but actually got %d.", i, expected, actual)
+		}
+	}
+}
+
+
+func TestClientReconnectGivesUpOnDroppedConnections(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	// The server accepts each connection, and then drops it right away.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var attempts []int
+
+	client := Client{
+		Caller: CallerFunc(func(ctx Context, w Writer, r Reader) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		}),
+		Reconnect: &ReconnectPolicy{
+			InitialDelay: time.Millisecond,
+			MaxAttempts:  3,
+			OnReconnect: func(attempt int, delay time.Duration, err error) {
+				attempts = append(attempts, attempt)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+
+	if err := client.DialAndCall(ctx, nil, "tcp", listener.Addr().String()); errReconnectGaveUp != err {
+		t.Fatalf("Expected %v, but actually got: (%T) %v", errReconnectGaveUp, err, err)
+	}
+
+	if expected, actual := 3, len(attempts); expected != actual {
+		t.Fatalf("Expected %d attempts, but actually got %d: %v", expected, actual, attempts)
+	}
+	for i, attempt := range attempts {
+		if expected, actual := i+1, attempt; expected != actual {
+			t.Errorf("For attempt #%d, expected %d, but actually got %d.", i, expected, actual)
+		}
+	}
+}
+
+
+func TestClientDialAndCallCanceled(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	// The server accepts each connection, and then sends nothing.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	for _, policy := range []*ReconnectPolicy{nil, &ReconnectPolicy{InitialDelay: time.Millisecond}} {
+
+		client := Client{
+			Caller: CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				_, err := ioutil.ReadAll(r)
+				return err
+			}),
+			Reconnect: policy,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+
+		results := make(chan error, 1)
+		go func() {
+			results <- client.DialAndCall(ctx, nil, "tcp", listener.Addr().String())
+		}()
+
+		select {
+		case err := <-results:
+			if context.DeadlineExceeded != err {
+				t.Errorf("Expected %v, but actually got: (%T) %v", context.DeadlineExceeded, err, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("DialAndCall did not return after 'ctx' was done.")
+		}
+
+		cancel()
+	}
+}
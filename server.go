@@ -74,6 +74,8 @@ type Server struct {
 
 	TLSConfig *tls.Config // optional TLS configuration; used by ListenAndServeTLS.
 
+	Lenient bool // if true, protocol errors from a client are logged and skipped over, rather than returned by Read (as a *ProtocolError).
+
 	Logger Logger
 }
 
@@ -170,10 +172,15 @@ func (server *Server) handle(c net.Conn, handler Handler) {
 		}
 	}()
 
-	var ctx Context = NewContext().InjectLogger(logger)
+	conn := NewServerConn(c, &ConnConfig{
+		Logger:  logger,
+		Lenient: server.Lenient,
+	})
+
+	var ctx Context = conn.ctx
 
-	var w Writer = newDataWriter(c)
-	var r Reader = newDataReader(c)
+	var w Writer = conn
+	var r Reader = conn
 
 	handler.ServeTELNET(ctx, w, r)
 	c.Close()
@@ -0,0 +1,86 @@
+package telnet
+
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+
+// A SessionEnd says why a TELNET (or TELNETS) session ended.
+type SessionEnd int
+
+const (
+	SessionEndLocalEOF      SessionEnd = iota // the Caller finished; e.g., it got to the end of its input.
+	SessionEndRemoteClose                     // the other side closed the connection.
+	SessionEndProtocolError                   // the other side sent something that broke the TELNET protocol. (See ProtocolError.)
+	SessionEndTimeout                         // a deadline or timeout passed.
+	SessionEndError                           // some other error; e.g., a failed write.
+)
+
+
+func (end SessionEnd) String() string {
+	switch end {
+	case SessionEndLocalEOF:
+		return "local EOF"
+	case SessionEndRemoteClose:
+		return "remote close"
+	case SessionEndProtocolError:
+		return "protocol error"
+	case SessionEndTimeout:
+		return "timeout"
+	case SessionEndError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+
+// A SessionResult describes how a TELNET (or TELNETS) session ended.
+//
+// Err is nil if the session ended normally; i.e., with SessionEndLocalEOF or SessionEndRemoteClose.
+type SessionResult struct {
+	End SessionEnd
+	Err error
+}
+
+
+// newSessionResult figures out how a session ended, from the error the Caller returned
+// ('err', which may be nil), and the errors seen reading from and writing to the connection.
+//
+// If 'reported' is true, then 'err' was returned by an ErrorCaller (or by logging in, or by
+// OnConnect); so a nil 'err' means the session ended normally, whatever errors were seen along
+// the way. (Since the ErrorCaller dealt with them; such as a read timeout it set up itself.)
+func newSessionResult(err error, reported bool, readErr error, writeErr error) SessionResult {
+
+	if nil == err {
+		switch {
+		case errors.Is(readErr, io.EOF):
+			return SessionResult{End:SessionEndRemoteClose}
+		case reported:
+			return SessionResult{End:SessionEndLocalEOF}
+		case nil != writeErr:
+			err = writeErr
+		case nil != readErr:
+			err = readErr
+		default:
+			return SessionResult{End:SessionEndLocalEOF}
+		}
+	}
+
+	var protocolError *ProtocolError
+	var netError net.Error
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return SessionResult{End:SessionEndRemoteClose}
+	case errors.As(err, &protocolError):
+		return SessionResult{End:SessionEndProtocolError, Err:err}
+	case errors.As(err, &netError) && netError.Timeout():
+		return SessionResult{End:SessionEndTimeout, Err:err}
+	default:
+		return SessionResult{End:SessionEndError, Err:err}
+	}
+}
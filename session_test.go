@@ -0,0 +1,145 @@
+package telnet
+
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"testing"
+)
+
+
+func TestClientCallSession(t *testing.T) {
+
+	errCaller := errors.New("caller error")
+
+	tests := []struct{
+		Sent     []byte
+		Caller   Caller
+		Expected SessionEnd
+		Err      error
+	}{
+		{
+			Sent:     []byte("Hello"),
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				_, err := ioutil.ReadAll(r)
+				return err
+			}),
+			Expected: SessionEndRemoteClose,
+		},
+		{
+			Sent:     []byte("Hello"),
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				return nil
+			}),
+			Expected: SessionEndLocalEOF,
+		},
+		{
+			Sent:     []byte("Hello"),
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				return errCaller
+			}),
+			Expected: SessionEndError,
+			Err:      errCaller,
+		},
+		{
+			Sent:     []byte{'a', 255, 'b'}, // 'a' IAC 'b'
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				_, err := ioutil.ReadAll(r)
+				return err
+			}),
+			Expected: SessionEndProtocolError,
+		},
+		{
+			Sent:     []byte{'a', 255, 'b'}, // 'a' IAC 'b'
+			Caller:   sessionTestCaller(func(ctx Context, w Writer, r Reader) { // a Caller that is not an ErrorCaller.
+				ioutil.ReadAll(r)
+			}),
+			Expected: SessionEndProtocolError,
+		},
+		{
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error {
+				r.(*Conn).SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+				_, err := ioutil.ReadAll(r)
+				return err
+			}),
+			Expected: SessionEndTimeout,
+		},
+		{
+			Caller:   CallerFunc(func(ctx Context, w Writer, r Reader) error { // an ErrorCaller that deals with its own timeout.
+				r.(*Conn).SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+				ioutil.ReadAll(r)
+				return nil
+			}),
+			Expected: SessionEndLocalEOF,
+		},
+		{
+			Caller:   sessionTestCaller(func(ctx Context, w Writer, r Reader) { // a Caller that is not an ErrorCaller.
+				r.(*Conn).SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+				ioutil.ReadAll(r)
+			}),
+			Expected: SessionEndTimeout,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		go func(sent []byte) {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			defer conn.Close()
+
+			conn.Write(sent)
+			if nil == sent {
+				// Send nothing, and keep the connection open, so that the client times out.
+				io.Copy(ioutil.Discard, conn)
+			}
+		}(test.Sent)
+
+		conn, err := DialTo(listener.Addr().String())
+		if nil != err {
+			listener.Close()
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		client := Client{Caller:test.Caller}
+
+		result := client.CallSession(conn)
+		listener.Close()
+
+		if expected, actual := test.Expected, result.End; expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got %v (%v).", testNumber, expected, actual, result.Err)
+			continue
+		}
+
+		if nil != test.Err && test.Err != result.Err {
+			t.Errorf("For test #%d, expected error %v, but actually got %v.", testNumber, test.Err, result.Err)
+			continue
+		}
+
+		var protocolError *ProtocolError
+		if expected, actual := SessionEndProtocolError == test.Expected, errors.As(result.Err, &protocolError); expected != actual {
+			t.Errorf("For test #%d, expected the error to be a *ProtocolError to be %t, but actually was %t: (%T) %v", testNumber, expected, actual, result.Err, result.Err)
+			continue
+		}
+	}
+}
+
+
+type sessionTestCaller func(Context, Writer, Reader)
+
+func (fn sessionTestCaller) CallTELNET(ctx Context, w Writer, r Reader) {
+	fn(ctx, w, r)
+}
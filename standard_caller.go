@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"sync"
 
 	"time"
 )
@@ -17,34 +19,158 @@ import (
 // StandardCaller is a simple TELNET client which sends to the server any data it gets from os.Stdin
 // as TELNET (and TELNETS) data, and writes any TELNET (or TELNETS) data it receives from
 // the server to os.Stdout, and writes any error it has to os.Stderr.
+//
+// If os.Stdin is a terminal, then (on Linux) StandardCaller puts it into raw mode while the
+// server does the ECHO and SUPPRESS-GO-AHEAD options. (I.e., "character at a time" mode.) That
+// way keystrokes are sent as soon as they are typed, and full-screen programs (such as vi and
+// top) and Ctrl-C work. The terminal is put back how it was when StandardCaller returns. If
+// os.Stdin is not a terminal, then it is sent a line at a time.
+//
+// When os.Stdin is a terminal, StandardCaller also tells the server the terminal type ($TERM),
+// with the TERMINAL-TYPE option, and (on Linux) the window size, with the NAWS option; and sends
+// the new window size whenever the terminal window is resized. That way full-screen programs on
+// the server render correctly.
+//
+// When os.Stdin is a terminal, typing the escape character (Ctrl-] by default) drops into a
+// local "telnet>" command prompt; type "help" there for the commands. To use a different
+// escape character, use NewStandardCaller.
+//
+// For scripts (i.e., when os.Stdin is piped from a file or another program), use NewStandardCaller
+// with batch mode. When os.Stdin ends, StandardCaller itself half-closes the connection (if it
+// can be) and waits for the server to close it, or to send nothing for a second; and it does not
+// send a last line without a trailing newline.
 var StandardCaller Caller = internalStandardCaller{}
 
 
-type internalStandardCaller struct{}
+// A StandardCallerConfig configures a Caller created with NewStandardCaller.
+//
+// The zero value gives the same Caller as StandardCaller.
+type StandardCallerConfig struct {
+	Escape   byte // the escape character, which drops into the "telnet>" command prompt; Ctrl-] (0x1D) if zero.
+	NoEscape bool // if true, then there is no escape character.
+
+	// Commands are "telnet>" commands (such as "mode character" or "toggle crlf") to do at the
+	// start of the session, when os.Stdin is a terminal. (For example, from a ~/.telnetrc file.)
+	Commands []string
+
+	// LineEditing, if true, turns on local line editing, when os.Stdin is a terminal.
+	//
+	// While in line mode (i.e., while the server does not echo), lines are then edited locally,
+	// readline-style: with cursor movement, word deletion, going through the history with the up
+	// and down arrow keys, searching the history with Ctrl-R, and completion with Tab. Ctrl-C sends
+	// a TELNET Interrupt Process; and Ctrl-D (on an empty line) ends the session. In "character at
+	// a time" mode, keystrokes are sent as-is, as usual.
+	LineEditing bool
+	HistoryDir  string                     // if set, then the history is kept in this directory; in a file per host.
+	Complete    func(line string) []string // if set, then Tab completes what was typed (up to the cursor) with what this returns.
+
+	// Batch, if true, turns on batch mode.
+	//
+	// In batch mode, all of os.Stdin is sent to the server a line at a time (including a last
+	// line without a trailing newline), and then the Caller waits for the server to finish:
+	// until the server sends something matching Prompt, if it is set (giving up if the server
+	// sends nothing for IdleTimeout, if that is set too); else until the server has sent nothing
+	// for IdleTimeout, if it is set; else it half-closes the connection and waits for the server
+	// to close it. Everything the server sent is written to os.Stdout before it returns.
+	//
+	// If Prompt is set, then each line is only sent once the server has shown the prompt (or has
+	// sent nothing more for IdleTimeout, or a second if that is not set); so that an earlier
+	// prompt, such as one shown on connecting, is not taken as the reply to the last line.
+	//
+	// With CallTELNETWithError, the error tells how it ended; it is nil if the server closed the
+	// connection, the prompt was received or the connection went idle (without a Prompt).
+	Batch       bool
+	IdleTimeout time.Duration  // in batch mode, how long the server can send nothing (after all of os.Stdin is sent) before giving up waiting.
+	Prompt      *regexp.Regexp // in batch mode, what the server sends (after the last line of os.Stdin) when it is done; such as a shell prompt.
+
+	// Transcript, if not nil, logs the session. (See Transcript.) This only works when the
+	// Reader is a *Conn; such as with a Client.
+	Transcript *Transcript
+}
+
+
+// NewStandardCaller returns a Caller that works like StandardCaller, but is configured by 'config'.
+func NewStandardCaller(config StandardCallerConfig) Caller {
+	return internalStandardCaller{config:config}
+}
+
+
+type internalStandardCaller struct {
+	config StandardCallerConfig
+}
 
 
 func (caller internalStandardCaller) CallTELNET(ctx Context, w Writer, r Reader) {
-	standardCallerCallTELNET(os.Stdin, os.Stdout, os.Stderr, ctx, w, r)
+	if err := caller.callTELNET(os.Stdin, os.Stdout, os.Stderr, ctx, w, r); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
 
 
-func standardCallerCallTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, ctx Context, w Writer, r Reader) {
+func (caller internalStandardCaller) CallTELNETWithError(ctx Context, w Writer, r Reader) error {
+	return caller.callTELNET(os.Stdin, os.Stdout, os.Stderr, ctx, w, r)
+}
+
+
+func standardCallerCallTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, ctx Context, w Writer, r Reader) error {
+	return internalStandardCaller{}.callTELNET(stdin, stdout, stderr, ctx, w, r)
+}
 
+
+func (caller internalStandardCaller) callTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, ctx Context, w Writer, r Reader) error {
+
+	if conn, ok := r.(*Conn); ok && nil != caller.config.Transcript {
+		conn.transcript = caller.config.Transcript
+	}
+
+	if caller.config.Batch {
+		return caller.callBatch(stdin, stdout, w, r)
+	}
+
+	if file, ok := stdin.(*os.File); ok && isTerminal(int(file.Fd())) {
+		session := newTerminalSession(file, stdout, w, r, caller.config)
+		return session.run()
+	}
+
+	// Nothing is written to 'stdout' after this returns. (The goroutine reading from the other
+	// side might not be done by then.)
+	var stdoutMutex sync.Mutex
+	stdoutClosed := false
+	defer func() {
+		stdoutMutex.Lock()
+		stdoutClosed = true
+		stdoutMutex.Unlock()
+	}()
+
+	readerDone := make(chan struct{})
+	received := make(chan struct{}, 1)
 	go func(writer io.Writer, reader io.Reader) {
+		defer close(readerDone)
 
-		var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
+		var buffer [1024]byte
 		p := buffer[:]
 
 		for {
-			// Read 1 byte.
 			n, err := reader.Read(p)
-			if n <= 0 && nil == err {
-				continue
-			} else if n <= 0 && nil != err {
-				break
+
+			if 0 < n {
+				stdoutMutex.Lock()
+				if stdoutClosed {
+					stdoutMutex.Unlock()
+					return
+				}
+				oi.LongWrite(writer, p[:n])
+				stdoutMutex.Unlock()
+
+				select {
+				case received <- struct{}{}:
+				default:
+				}
 			}
 
-			oi.LongWrite(writer, p)
+			if nil != err {
+				break
+			}
 		}
 	}(stdout, r)
 
@@ -67,20 +193,41 @@ func standardCallerCallTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr
 
 		n, err := oi.LongWrite(w, p)
 		if nil != err {
-			break
+			return err
 		}
 		if expected, actual := int64(len(p)), n; expected != actual {
 			err := fmt.Errorf("Transmission problem: tried sending %d bytes, but actually only sent %d bytes.", expected, actual)
-			fmt.Fprint(stderr, err.Error())
-			return
+			return err
 		}
 
 
 		buffer.Reset()
 	}
+	if err := scanner.Err(); nil != err {
+		return err
+	}
 
-	// Wait a bit to receive data from the server (that we would send to io.Stdout).
-	time.Sleep(3 * time.Millisecond)
+	// Wait to receive data from the server (that we would send to io.Stdout): half-close the
+	// connection (if it can be), and wait for the server to close it; or for it to send nothing
+	// for a while.
+	closeWrite(w)
+
+	idle := time.NewTimer(defaultBatchIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-readerDone:
+			return nil
+		case <-received:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(defaultBatchIdleTimeout)
+		case <-idle.C:
+			return nil
+		}
+	}
 }
 
 
@@ -91,3 +238,4 @@ func scannerSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err e
 
 	return bufio.ScanLines(data, atEOF)
 }
+
@@ -129,130 +129,133 @@ func (telnetHandler *ShellHandler) ServeTELNET(ctx telnet.Context, writer telnet
 	exitMessage     = telnetHandler.ExitMessage
 
 
+	// Offer END-OF-RECORD, so that the ends of prompts can be marked.
+	if conn, ok := writer.(*telnet.Conn); ok {
+		if err := conn.OfferEndOfRecord(); nil != err {
+			logger.Errorf("Problem offering END-OF-RECORD: %v", err)
+			return
+		}
+	}
+
 	if _, err := oi.LongWriteString(writer, welcomeMessage); nil != err {
 		logger.Errorf("Problem long writing welcome message: %v", err)
 		return
 	}
 	logger.Debugf("Wrote welcome message: %q.", welcomeMessage)
-	if _, err := oi.LongWrite(writer, promptBytes); nil != err {
+	if err := writePrompt(writer, promptBytes); nil != err {
 		logger.Errorf("Problem long writing prompt: %v", err)
 		return
 	}
 	logger.Debugf("Wrote prompt: %q.", promptBytes)
 
 
-	var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
+	var buffer [1024]byte
 	p := buffer[:]
 
 	var line bytes.Buffer
 
 	for {
-		// Read 1 byte.
 		n, err := reader.Read(p)
-		if n <= 0 && nil == err {
-			continue
-		} else if n <= 0 && nil != err {
-			break
-		}
-
 
-		line.WriteByte(p[0])
-		//logger.Tracef("Received: %q (%d).", p[0], p[0])
+		for _, b := range p[:n] {
+			line.WriteByte(b)
+			//logger.Tracef("Received: %q (%d).", b, b)
 
 
-		if '\n' == p[0] {
-			lineString := line.String()
+			if '\n' == b {
+				lineString := line.String()
 
-			if "\r\n" == lineString {
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
+				if "\r\n" == lineString {
+					line.Reset()
+					if err := writePrompt(writer, promptBytes); nil != err {
+						return
+					}
+					continue
 				}
-				continue
-			}
 
 
 //@TODO: support piping.
-			fields := strings.Fields(lineString)
-			logger.Debugf("Have %d tokens.", len(fields))
-			logger.Tracef("Tokens: %v", fields)
-			if len(fields) <= 0 {
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
+				fields := strings.Fields(lineString)
+				logger.Debugf("Have %d tokens.", len(fields))
+				logger.Tracef("Tokens: %v", fields)
+				if len(fields) <= 0 {
+					line.Reset()
+					if err := writePrompt(writer, promptBytes); nil != err {
+						return
+					}
+					continue
 				}
-				continue
-			}
 
 
-			field0 := fields[0]
-
-			if exitCommandName == field0 {
-				oi.LongWriteString(writer, exitMessage)
-				return
-			}
+				field0 := fields[0]
 
+				if exitCommandName == field0 {
+					oi.LongWriteString(writer, exitMessage)
+					return
+				}
 
-			var producer Producer
 
-			telnetHandler.muxtex.RLock()
-			var ok bool
-			producer, ok = telnetHandler.producers[field0]
-			telnetHandler.muxtex.RUnlock()
+				var producer Producer
 
-			if !ok {
 				telnetHandler.muxtex.RLock()
-				producer = telnetHandler.elseProducer
+				var ok bool
+				producer, ok = telnetHandler.producers[field0]
 				telnetHandler.muxtex.RUnlock()
-			}
 
-			if nil == producer {
+				if !ok {
+					telnetHandler.muxtex.RLock()
+					producer = telnetHandler.elseProducer
+					telnetHandler.muxtex.RUnlock()
+				}
+
+				if nil == producer {
 //@TODO: Don't convert that to []byte! think this creates "garbage" (for collector).
-				oi.LongWrite(writer, []byte(field0))
-				oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
+					oi.LongWrite(writer, []byte(field0))
+					oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
+					line.Reset()
+					if err := writePrompt(writer, promptBytes); nil != err {
+						return
+					}
+					continue
 				}
-				continue
-			}
 
-			handler := producer.Produce(ctx, field0, fields[1:]...)
-			if nil == handler {
-				oi.LongWrite(writer, []byte(field0))
+				handler := producer.Produce(ctx, field0, fields[1:]...)
+				if nil == handler {
+					oi.LongWrite(writer, []byte(field0))
 //@TODO: Need to use a different error message.
-				oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
-				line.Reset()
-				oi.LongWrite(writer, promptBytes)
-				continue
-			}
+					oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
+					line.Reset()
+					writePrompt(writer, promptBytes)
+					continue
+				}
 
 //@TODO: Wire up the stdin, stdout, stderr of the handler.
 
-			if stdoutPipe, err := handler.StdoutPipe(); nil != err {
+				if stdoutPipe, err := handler.StdoutPipe(); nil != err {
 //@TODO:                              
-			} else if nil == stdoutPipe {
+				} else if nil == stdoutPipe {
 //@TODO:                              
-			} else {
-				connect(ctx, writer, stdoutPipe)
-			}
+				} else {
+					connect(ctx, writer, stdoutPipe)
+				}
 
 
-			if stderrPipe, err := handler.StderrPipe(); nil != err {
+				if stderrPipe, err := handler.StderrPipe(); nil != err {
 //@TODO:                              
-			} else if nil == stderrPipe {
+				} else if nil == stderrPipe {
 //@TODO:                              
-			} else {
-				connect(ctx, writer, stderrPipe)
-			}
+				} else {
+					connect(ctx, writer, stderrPipe)
+				}
 
 
-			if err := handler.Run(); nil != err {
+				if err := handler.Run(); nil != err {
 //@TODO:                                    
-			}
-			line.Reset()
-			if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-				return
+				}
+				line.Reset()
+				if err := writePrompt(writer, promptBytes); nil != err {
+					return
+				}
 			}
 		}
 
@@ -276,22 +279,39 @@ func connect(ctx telnet.Context, writer io.Writer, reader io.Reader) {
 
 	go func(logger telnet.Logger){
 
-		var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
+		var buffer [1024]byte
 		p := buffer[:]
 
 		for {
-			// Read 1 byte.
 			n, err := reader.Read(p)
-			if n <= 0 && nil == err {
-				continue
-			} else if n <= 0 && nil != err {
-				break
-			}
 
-			//logger.Tracef("Sending: %q.", p)
+			if 0 < n {
+				//logger.Tracef("Sending: %q.", p[:n])
 //@TODO: Should we be checking for errors?
-			oi.LongWrite(writer, p)
-			//logger.Tracef("Sent: %q.", p)
+				oi.LongWrite(writer, p[:n])
+				//logger.Tracef("Sent: %q.", p[:n])
+			}
+
+			if nil != err {
+				break
+			}
 		}
 	}(logger)
 }
+
+
+// writePrompt writes the prompt, and then marks the end of it. (So that clients that care,
+// such as MUD clients, can tell where the prompt ends.)
+func writePrompt(writer telnet.Writer, promptBytes []byte) error {
+	if _, err := oi.LongWrite(writer, promptBytes); nil != err {
+		return err
+	}
+
+	if recordWriter, ok := writer.(telnet.RecordWriter); ok {
+		if err := recordWriter.WriteEndOfRecord(); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
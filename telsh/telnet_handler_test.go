@@ -108,3 +108,35 @@ func TestServeTELNETCommandNotFound(t *testing.T) {
 		}
 	}
 }
+
+
+type recordingWriter struct {
+	bytes.Buffer
+}
+
+func (w *recordingWriter) WriteEndOfRecord() error {
+	w.WriteString("<EOR>")
+	return nil
+}
+
+
+func TestServeTELNETMarksEndOfPrompt(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+
+	ctx := telnet.NewContext()
+
+	var writer recordingWriter
+
+	shellHandler.ServeTELNET(ctx, &writer, strings.NewReader("\r\napple\r\n"))
+
+	expected := shellHandler.WelcomeMessage +
+		shellHandler.Prompt + "<EOR>" +
+		shellHandler.Prompt + "<EOR>" +
+		"apple: command not found\r\n" +
+		shellHandler.Prompt + "<EOR>" +
+		shellHandler.ExitMessage
+	if actual := writer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
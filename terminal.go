@@ -0,0 +1,134 @@
+package telnet
+
+
+import (
+	"sync"
+)
+
+
+// An internalTerminal puts a local terminal into (and out of) raw mode.
+//
+// In raw mode, keystrokes are available as soon as they are typed (rather than a line at
+// a time), they are not echoed locally, and keys such as Ctrl-C are passed through as-is
+// (rather than generating signals). This is what "character at a time" TELNET needs; i.e.,
+// when the server does the ECHO and SUPPRESS-GO-AHEAD options.
+//
+// Raw mode is only supported on Linux. (See terminal_linux.go.) Elsewhere, the terminal just
+// stays in line mode.
+type internalTerminal struct {
+	mutex   sync.Mutex
+	fd      int
+	saved   *terminalState // nil unless in raw mode.
+	line    *terminalState // nil unless the escape character was made to end lines. (See setLineEscape.)
+	wantRaw bool           // what setRaw was last asked for.
+	paused  bool           // if true, then stay out of raw mode, no matter what setRaw is asked for.
+}
+
+
+func newTerminal(fd int) *internalTerminal {
+	terminal := internalTerminal{
+		fd:fd,
+	}
+
+	return &terminal
+}
+
+
+// setRaw puts the terminal into raw mode if 'raw' is true, else back how it was.
+//
+// (If the terminal is paused, then this is just remembered, until it is resumed.)
+func (terminal *internalTerminal) setRaw(raw bool) error {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	terminal.wantRaw = raw
+
+	return terminal.apply(raw && !terminal.paused)
+}
+
+
+func (terminal *internalTerminal) apply(raw bool) error {
+	switch {
+	case raw && nil == terminal.saved:
+		saved, err := makeRaw(terminal.fd)
+		if nil != err {
+			return err
+		}
+		terminal.saved = saved
+	case !raw && nil != terminal.saved:
+		if err := restoreTerminal(terminal.fd, terminal.saved); nil != err {
+			return err
+		}
+		terminal.saved = nil
+	}
+
+	return nil
+}
+
+
+// setLineEscape makes the escape character, 'escape', end a line when the terminal is in line
+// mode. Otherwise, in line mode, the escape character would not be seen until Enter was typed.
+//
+// (Raw mode is made from, and goes back to, this; restore undoes it.)
+func (terminal *internalTerminal) setLineEscape(escape byte) error {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	if nil != terminal.line || nil != terminal.saved {
+		return nil
+	}
+
+	line, err := makeLineEscape(terminal.fd, escape)
+	if nil != err {
+		return err
+	}
+	terminal.line = line
+
+	return nil
+}
+
+
+// pause takes the terminal out of raw mode (if it is in it), until resume is called. (For
+// example, while at the "telnet>" command prompt.)
+func (terminal *internalTerminal) pause() error {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	terminal.paused = true
+
+	return terminal.apply(false)
+}
+
+
+// resume undoes pause.
+func (terminal *internalTerminal) resume() error {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	terminal.paused = false
+
+	return terminal.apply(terminal.wantRaw)
+}
+
+
+// isRaw reports whether the terminal is in raw mode.
+func (terminal *internalTerminal) isRaw() bool {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	return nil != terminal.saved
+}
+
+
+// restore puts the terminal back how it was (if it is in raw mode, or setLineEscape was used).
+func (terminal *internalTerminal) restore() {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	terminal.apply(false)
+
+	if nil != terminal.line {
+		restoreTerminal(terminal.fd, terminal.line)
+		terminal.line = nil
+	}
+}
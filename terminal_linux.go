@@ -0,0 +1,162 @@
+//go:build linux
+// +build linux
+
+package telnet
+
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+
+type terminalState struct {
+	termios syscall.Termios
+}
+
+
+func getTermios(fd int) (*syscall.Termios, error) {
+	var termios syscall.Termios
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); 0 != errno {
+		return nil, errno
+	}
+
+	return &termios, nil
+}
+
+
+func setTermios(fd int, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(termios))); 0 != errno {
+		return errno
+	}
+
+	return nil
+}
+
+
+// isTerminal reports whether 'fd' is a terminal.
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return nil == err
+}
+
+
+// makeRaw puts the terminal 'fd' into raw mode, and returns its previous state (so that
+// it can be restored).
+func makeRaw(fd int) (*terminalState, error) {
+	termios, err := getTermios(fd)
+	if nil != err {
+		return nil, err
+	}
+
+	saved := terminalState{termios:*termios}
+
+	raw := rawTermios(*termios)
+	if err := setTermios(fd, &raw); nil != err {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+
+// rawTermios returns 'termios' changed to raw mode. (The same way as cfmakeraw(3).)
+func rawTermios(termios syscall.Termios) syscall.Termios {
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	return termios
+}
+
+
+// makeLineEscape makes 'escape' end a line (as VEOL) on the terminal 'fd'. So that, in line
+// mode, a read returns as soon as the escape character is typed, rather than after Enter. It
+// returns the previous state (so that it can be restored).
+func makeLineEscape(fd int, escape byte) (*terminalState, error) {
+	termios, err := getTermios(fd)
+	if nil != err {
+		return nil, err
+	}
+
+	saved := terminalState{termios:*termios}
+
+	line := lineTermios(*termios, escape)
+	if err := setTermios(fd, &line); nil != err {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+
+// lineTermios returns 'termios' changed so that 'escape' also ends a line. (The same way as
+// BSD telnet does in line mode.)
+func lineTermios(termios syscall.Termios, escape byte) syscall.Termios {
+	termios.Cc[syscall.VEOL] = escape
+
+	return termios
+}
+
+
+// restoreTerminal puts the terminal 'fd' back into 'state'.
+func restoreTerminal(fd int, state *terminalState) error {
+	return setTermios(fd, &state.termios)
+}
+
+
+// suspend stops this process (as Ctrl-Z would, in a shell), until it is continued.
+func suspend() error {
+	return syscall.Kill(0, syscall.SIGTSTP)
+}
+
+
+// A winsize is what the TIOCGWINSZ and TIOCSWINSZ ioctls take.
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+
+// windowSize returns the width and height (in characters) of the terminal 'fd'.
+func windowSize(fd int) (width int, height int, err error) {
+	var size winsize
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size))); 0 != errno {
+		return 0, 0, errno
+	}
+
+	return int(size.Col), int(size.Row), nil
+}
+
+
+// setWindowSize sets the width and height (in characters) of the terminal 'fd'. (Which, for a
+// pty, sends SIGWINCH to its foreground process group.)
+func setWindowSize(fd int, width int, height int) error {
+	size := winsize{
+		Row: uint16(height),
+		Col: uint16(width),
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&size))); 0 != errno {
+		return errno
+	}
+
+	return nil
+}
+
+
+// notifyWindowSize makes it so 'ch' receives a signal whenever the size of the terminal window
+// changes. (I.e., on SIGWINCH.)
+func notifyWindowSize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
@@ -0,0 +1,122 @@
+//go:build linux
+// +build linux
+
+package telnet
+
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"testing"
+)
+
+
+func TestRawTermios(t *testing.T) {
+
+	var termios syscall.Termios
+	termios.Iflag = syscall.ICRNL | syscall.IXON
+	termios.Oflag = syscall.OPOST
+	termios.Lflag = syscall.ECHO | syscall.ICANON | syscall.ISIG
+
+	raw := rawTermios(termios)
+
+	if 0 != raw.Lflag & (syscall.ECHO | syscall.ICANON | syscall.ISIG) {
+		t.Errorf("Expected ECHO, ICANON and ISIG to be off, but actually the lflag is %#x.", raw.Lflag)
+	}
+	if 0 != raw.Iflag & (syscall.ICRNL | syscall.IXON) {
+		t.Errorf("Expected ICRNL and IXON to be off, but actually the iflag is %#x.", raw.Iflag)
+	}
+	if 0 != raw.Oflag & syscall.OPOST {
+		t.Errorf("Expected OPOST to be off, but actually the oflag is %#x.", raw.Oflag)
+	}
+	if expected, actual := uint8(1), raw.Cc[syscall.VMIN]; expected != actual {
+		t.Errorf("Expected VMIN to be %d, but actually was %d.", expected, actual)
+	}
+}
+
+
+func TestIsTerminalPipe(t *testing.T) {
+
+	r, w, err := os.Pipe()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if isTerminal(int(r.Fd())) {
+		t.Errorf("Expected a pipe to not be a terminal.")
+	}
+}
+
+
+func TestLineTermios(t *testing.T) {
+
+	var termios syscall.Termios
+	termios.Lflag = syscall.ECHO | syscall.ICANON | syscall.ISIG
+
+	line := lineTermios(termios, 0x1d)
+
+	if expected, actual := uint8(0x1d), line.Cc[syscall.VEOL]; expected != actual {
+		t.Errorf("Expected VEOL to be %#x, but actually was %#x.", expected, actual)
+	}
+	if expected, actual := termios.Lflag, line.Lflag; expected != actual {
+		t.Errorf("Expected the lflag to be unchanged (%#x), but actually was %#x.", expected, actual)
+	}
+}
+
+
+func TestTerminalLineEscape(t *testing.T) {
+
+	master, slave, err := openPty()
+	if nil != err {
+		t.Skipf("Could not open a pty: (%T) %v", err, err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	fd := int(slave.Fd())
+
+	before, err := getTermios(fd)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	terminal := newTerminal(fd)
+	if err := terminal.setLineEscape(0x1d); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// No Enter; the escape character alone should end the line.
+	if _, err := master.Write([]byte("ab\x1d")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	read := make(chan string, 1)
+	go func() {
+		p := make([]byte, 64)
+		n, _ := slave.Read(p)
+		read <- string(p[:n])
+	}()
+
+	select {
+	case actual := <-read:
+		if expected := "ab\x1d"; expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the read to return once the escape character was typed, but it did not.")
+	}
+
+	terminal.restore()
+
+	after, err := getTermios(fd)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := before.Cc[syscall.VEOL], after.Cc[syscall.VEOL]; expected != actual {
+		t.Errorf("Expected VEOL to be restored to %#x, but actually was %#x.", expected, actual)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !linux
+// +build !linux
+
+package telnet
+
+
+import (
+	"errors"
+	"os"
+)
+
+
+var (
+	errRawModeNotSupported    = errors.New("Raw terminal mode is not supported on this system.")
+	errLineEscapeNotSupported = errors.New("Changing the terminal's end of line characters is not supported on this system.")
+	errSuspendNotSupported    = errors.New("Suspending is not supported on this system.")
+	errWindowSizeNotSupported = errors.New("Getting the terminal window size is not supported on this system.")
+)
+
+
+type terminalState struct{}
+
+
+// isTerminal reports whether 'fd' is a terminal. (Raw mode is not supported here, so this
+// always reports false, which keeps StandardCaller in line mode.)
+func isTerminal(fd int) bool {
+	return false
+}
+
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errRawModeNotSupported
+}
+
+
+func makeLineEscape(fd int, escape byte) (*terminalState, error) {
+	return nil, errLineEscapeNotSupported
+}
+
+
+func restoreTerminal(fd int, state *terminalState) error {
+	return errRawModeNotSupported
+}
+
+
+func suspend() error {
+	return errSuspendNotSupported
+}
+
+
+func windowSize(fd int) (width int, height int, err error) {
+	return 0, 0, errWindowSizeNotSupported
+}
+
+
+// notifyWindowSize does nothing here. (There is no SIGWINCH.)
+func notifyWindowSize(ch chan<- os.Signal) {
+}
@@ -0,0 +1,678 @@
+package telnet
+
+
+import (
+	"github.com/reiver/go-oi"
+
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+)
+
+
+// defaultEscape is the default escape character; i.e., Ctrl-]. (The same as BSD telnet.)
+const defaultEscape = 0x1D
+
+
+// An internalTerminalSession is what StandardCaller does when stdin is a terminal.
+//
+// It passes what is typed to the other side, and what the other side sends to stdout; puts the
+// terminal into raw mode when the other side wants "character at a time" mode; tells the other
+// side the terminal type and window size (and when the window size changes); and, when the
+// escape character is typed, drops into a local "telnet>" command prompt.
+//
+// If line editing is turned on, then (in line mode) what is typed is edited locally with an
+// internalLineEditor, and sent a line at a time.
+type internalTerminalSession struct {
+	stdin    *os.File
+	stdout   io.Writer
+	w        Writer
+	r        Reader
+	conn     *Conn // nil if 'r' is not a *Conn.
+	terminal *internalTerminal
+
+	escape   byte
+	noEscape bool
+
+	crlf bool // if true, then in raw mode, Enter is sent as CR LF (rather than CR NUL).
+
+	commands []string // "telnet>" commands to do at the start of the session.
+
+	editor      *internalLineEditor // nil if line editing is not turned on.
+	historyPath string              // "" if the history is not saved.
+
+	outputMutex sync.Mutex
+	outputLine  []byte // what the other side sent since its last line ending; i.e., probably its prompt.
+}
+
+
+func newTerminalSession(stdin *os.File, stdout io.Writer, w Writer, r Reader, config StandardCallerConfig) *internalTerminalSession {
+	conn, _ := r.(*Conn)
+
+	escape := config.Escape
+	if 0 == escape {
+		escape = defaultEscape
+	}
+
+	session := internalTerminalSession{
+		stdin:stdin,
+		stdout:stdout,
+		w:w,
+		r:r,
+		conn:conn,
+		terminal:newTerminal(int(stdin.Fd())),
+		escape:escape,
+		noEscape:config.NoEscape,
+		commands:config.Commands,
+	}
+
+	if config.LineEditing {
+		session.startLineEditing(config)
+	}
+
+	return &session
+}
+
+
+// startLineEditing creates the line editor; with the history (for the host on the other side)
+// from config.HistoryDir, if it is set.
+func (session *internalTerminalSession) startLineEditing(config StandardCallerConfig) {
+
+	var history []string
+
+	if "" != config.HistoryDir && nil != session.conn {
+		session.historyPath = historyPath(config.HistoryDir, session.conn.dialedAddr())
+
+		var err error
+		history, err = loadHistory(session.historyPath)
+		if nil != err {
+			session.conn.negotiator.logger().Warnf("Could not load the history from %q: %v", session.historyPath, err)
+		}
+	}
+
+	editor := newLineEditor(session.stdout, history)
+	editor.prompt = session.prompt
+	editor.complete = config.Complete
+	editor.onHistory = session.saveHistory
+
+	session.editor = editor
+}
+
+
+func (session *internalTerminalSession) saveHistory(line string) {
+	if "" == session.historyPath {
+		return
+	}
+
+	if err := appendHistory(session.historyPath, line); nil != err {
+		session.conn.negotiator.logger().Warnf("Could not save the history to %q: %v", session.historyPath, err)
+	}
+}
+
+
+// trackOutput remembers what the other side sent since its last line ending. (So that the line
+// editor can draw the prompt again.)
+func (session *internalTerminalSession) trackOutput(p []byte) {
+	session.outputMutex.Lock()
+	defer session.outputMutex.Unlock()
+
+	if i := bytes.LastIndexAny(p, "\r\n"); 0 <= i {
+		session.outputLine = append(session.outputLine[:0], p[i+1:]...)
+	} else {
+		session.outputLine = append(session.outputLine, p...)
+	}
+}
+
+
+// prompt returns what the other side sent since its last line ending.
+func (session *internalTerminalSession) prompt() string {
+	session.outputMutex.Lock()
+	defer session.outputMutex.Unlock()
+
+	return string(session.outputLine)
+}
+
+
+// wantRaw reports whether the terminal should be in raw mode; i.e., in "character at a time"
+// mode, or if the line editor is being used.
+func (session *internalTerminalSession) wantRaw() bool {
+	return nil != session.editor || session.characterMode()
+}
+
+
+// characterMode reports whether the other side wants "character at a time" mode; i.e.,
+// it echoes, and does not send go aheads.
+func (session *internalTerminalSession) characterMode() bool {
+	if nil == session.conn {
+		return false
+	}
+
+	negotiator := session.conn.negotiator
+
+	return negotiator.remoteEnabled(optionEcho) && negotiator.remoteEnabled(optionSuppressGoAhead)
+}
+
+
+// binary reports whether we are sending with the TRANSMIT-BINARY option, in "character at a
+// time" mode.
+func (session *internalTerminalSession) binary() bool {
+	if nil == session.conn {
+		return false
+	}
+
+	return session.conn.negotiator.localEnabled(optionBinary) && session.terminal.isRaw()
+}
+
+
+// announceTerminal puts the terminal type ($TERM) and window size into the context, and offers
+// the TERMINAL-TYPE and NAWS options, so that the other side can learn them.
+//
+// (The other side might have asked for these before we knew them; in which case they would have
+// been refused. So they are offered here, rather than just waiting to be asked.)
+func (session *internalTerminalSession) announceTerminal() {
+	if nil == session.conn {
+		return
+	}
+
+	ctx        := session.conn.ctx
+	negotiator := session.conn.negotiator
+
+	if terminalType := os.Getenv("TERM"); "" != terminalType {
+		ctx.InjectTerminalType(terminalType)
+		negotiator.offerLocal(optionTerminalType)
+	}
+
+	if width, height, err := windowSize(int(session.stdin.Fd())); nil == err && 0 < width && 0 < height {
+		ctx.InjectWindowSize(width, height)
+		negotiator.offerLocal(optionWindowSize)
+	}
+}
+
+
+// watchWindowSize sends the new window size to the other side whenever the terminal window is
+// resized, until 'done' is closed.
+func (session *internalTerminalSession) watchWindowSize(done <-chan struct{}) {
+	if nil == session.conn {
+		return
+	}
+
+	changed := make(chan os.Signal, 1)
+	notifyWindowSize(changed)
+	defer signal.Stop(changed)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-changed:
+			width, height, err := windowSize(int(session.stdin.Fd()))
+			if nil != err || 0 >= width || 0 >= height {
+				continue
+			}
+
+			session.conn.ctx.InjectWindowSize(width, height)
+			session.conn.negotiator.sendWindowSize()
+		}
+	}
+}
+
+
+func (session *internalTerminalSession) run() error {
+
+	terminal := session.terminal
+	defer terminal.restore()
+
+	// So that, in line mode, the escape character works as soon as it is typed.
+	if !session.noEscape {
+		terminal.setLineEscape(session.escape)
+	}
+
+	session.announceTerminal()
+
+	// The user is at a terminal; so ^S and ^Q can stop and restart the output, if the server
+	// asks for TOGGLE-FLOW-CONTROL.
+	if nil != session.conn {
+		session.conn.AcceptFlowControl()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go session.watchWindowSize(done)
+
+	remoteDone := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				terminal.restore()
+				panic(r)
+			}
+		}()
+
+		var buffer [1024]byte
+		p := buffer[:]
+
+		for {
+			n, err := session.r.Read(p)
+
+			if 0 < n {
+				oi.LongWrite(session.stdout, p[:n])
+				if nil != session.editor {
+					session.trackOutput(p[:n])
+				}
+			}
+
+			// Options get negotiated as a side effect of reading. So this is when to check
+			// whether to switch between raw mode and line mode.
+			terminal.setRaw(session.wantRaw())
+
+			if nil != err {
+				remoteDone <- err
+				return
+			}
+		}
+	}()
+
+	terminal.setRaw(session.wantRaw())
+
+	localDone := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				terminal.restore()
+				panic(r)
+			}
+		}()
+
+		localDone <- session.readStdin()
+	}()
+
+	// Reading stdin cannot be interrupted. So, if the server closes the connection first,
+	// then the goroutine reading stdin is just left behind.
+	select {
+	case err := <-localDone:
+		return err
+	case err := <-remoteDone:
+		if io.EOF == err {
+			return nil
+		}
+		return err
+	}
+}
+
+
+// readStdin sends what is typed to the other side, until stdin is closed, or the "close"
+// command is used.
+func (session *internalTerminalSession) readStdin() error {
+
+	for _, command := range session.commands {
+		closed, err := session.command(command)
+		if nil != err {
+			return err
+		}
+		if closed {
+			return nil
+		}
+	}
+
+	var buffer [1024]byte
+	p := buffer[:]
+
+	var translated []byte
+
+	for {
+		n, err := session.stdin.Read(p)
+
+		data := p[:n]
+		for 0 < len(data) {
+			typed := data
+			escaped := false
+			if !session.noEscape {
+				if i := bytes.IndexByte(data, session.escape); 0 <= i {
+					typed = data[:i]
+					escaped = true
+				}
+			}
+
+			if nil != session.editor && !session.characterMode() {
+				closed, err := session.edit(typed)
+				if nil != err {
+					return err
+				}
+				if closed {
+					return nil
+				}
+			} else if session.binary() {
+				// In binary mode, a CR is just a CR.
+				translated = append(translated[:0], typed...)
+				if _, err := oi.LongWrite(session.w, translated); nil != err {
+					return err
+				}
+			} else {
+				translated = translateTerminalInput(translated[:0], typed, session.terminal.isRaw(), session.crlf)
+				if _, err := oi.LongWrite(session.w, translated); nil != err {
+					return err
+				}
+			}
+			data = data[len(typed):]
+
+			if escaped {
+				data = data[1:]
+
+				closed, err := session.commandMode()
+				if nil != err {
+					return err
+				}
+				if closed {
+					return nil
+				}
+
+				// Whatever else was typed along with the escape character is dropped,
+				// as the command prompt was in the way.
+				data = nil
+			}
+		}
+
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+	}
+}
+
+
+// edit passes what was typed to the line editor; and sends each line it returns to the other
+// side. It returns true if the session should be closed. (I.e., Ctrl-D on an empty line; the
+// same as the end of stdin.)
+func (session *internalTerminalSession) edit(typed []byte) (closed bool, err error) {
+	for _, b := range typed {
+		line, action := session.editor.feed(b)
+
+		switch action {
+		case lineEditorLine:
+			if _, err := oi.LongWrite(session.w, []byte(line + "\r\n")); nil != err {
+				return false, err
+			}
+		case lineEditorInterrupt:
+			if nil != session.conn {
+				if err := session.conn.negotiator.sendCommand(cmdIP); nil != err {
+					return false, err
+				}
+			}
+		case lineEditorEOF:
+			return true, nil
+		case lineEditorSuspend:
+			session.terminal.pause()
+			if err := suspend(); nil != err {
+				fmt.Fprintf(session.stdout, "%v\r\n", err)
+			}
+			session.terminal.resume()
+			session.editor.redraw()
+		}
+	}
+
+	return false, nil
+}
+
+
+// commandMode shows the "telnet>" prompt, and does the command typed there. (See command.) It
+// returns true if the session should be closed.
+func (session *internalTerminalSession) commandMode() (closed bool, err error) {
+
+	session.terminal.pause()
+	defer session.terminal.resume()
+
+	fmt.Fprint(session.stdout, "\r\ntelnet> ")
+
+	line, err := session.readLine()
+	if nil != err && io.EOF != err {
+		return false, err
+	}
+
+	if "" == strings.TrimSpace(line) {
+		// Just resume the session.
+		return io.EOF == err, nil
+	}
+
+	return session.command(line)
+}
+
+
+// command does the "telnet>" command 'line'. It returns true if the session should be closed.
+func (session *internalTerminalSession) command(line string) (closed bool, err error) {
+
+	fields := strings.Fields(line)
+	if len(fields) <= 0 {
+		return false, nil
+	}
+
+	switch fields[0] {
+	case "close", "quit":
+		fmt.Fprint(session.stdout, "Connection closed.\r\n")
+		return true, nil
+	case "send":
+		return false, session.send(fields[1:])
+	case "status":
+		session.status()
+	case "mode":
+		return false, session.mode(fields[1:])
+	case "toggle":
+		session.toggle(fields[1:])
+	case "z":
+		if err := suspend(); nil != err {
+			fmt.Fprintf(session.stdout, "%v\r\n", err)
+		}
+	case "help", "?":
+		fmt.Fprint(session.stdout,
+			"Commands are:\r\n" +
+			"\r\n" +
+			"close       close current connection\r\n" +
+			"send        transmit special characters ('send ?' for more)\r\n" +
+			"status      print status information\r\n" +
+			"mode        try to enter line or character mode ('mode ?' for more)\r\n" +
+			"toggle      toggle operating parameters ('toggle ?' for more)\r\n" +
+			"z           suspend telnet\r\n" +
+			"?           print help information\r\n")
+	default:
+		fmt.Fprintf(session.stdout, "?Invalid command\r\n")
+	}
+
+	return false, nil
+}
+
+
+// readLine reads a line (in line mode) from stdin, without the line ending.
+func (session *internalTerminalSession) readLine() (string, error) {
+	var line []byte
+	var b [1]byte
+
+	for {
+		n, err := session.stdin.Read(b[:])
+		if 0 < n {
+			if '\n' == b[0] {
+				return strings.TrimSuffix(string(line), "\r"), nil
+			}
+			line = append(line, b[0])
+		}
+		if nil != err {
+			return string(line), err
+		}
+	}
+}
+
+
+var terminalSendCommands = map[string]byte{
+	"ao":    cmdAO,
+	"ayt":   cmdAYT,
+	"brk":   cmdBRK,
+	"break": cmdBRK,
+	"ec":    cmdEC,
+	"el":    cmdEL,
+	"ga":    cmdGA,
+	"ip":    cmdIP,
+	"nop":   cmdNOP,
+}
+
+
+func (session *internalTerminalSession) send(args []string) error {
+	if len(args) <= 0 || "?" == args[0] {
+		fmt.Fprint(session.stdout,
+			"ao          send TELNET Abort output\r\n" +
+			"ayt         send TELNET 'Are You There'\r\n" +
+			"brk         send TELNET Break\r\n" +
+			"ec          send TELNET Erase Character\r\n" +
+			"el          send TELNET Erase Line\r\n" +
+			"escape      send current escape character\r\n" +
+			"ga          send TELNET 'Go Ahead' sequence\r\n" +
+			"ip          send TELNET Interrupt Process\r\n" +
+			"nop         send TELNET 'No operation'\r\n")
+		return nil
+	}
+
+	for _, arg := range args {
+		if "escape" == arg {
+			if _, err := oi.LongWrite(session.w, []byte{session.escape}); nil != err {
+				return err
+			}
+			continue
+		}
+
+		command, ok := terminalSendCommands[arg]
+		if !ok {
+			fmt.Fprintf(session.stdout, "?Unknown send argument '%s'\r\n", arg)
+			return nil
+		}
+
+		if nil == session.conn {
+			fmt.Fprint(session.stdout, "?Not connected\r\n")
+			return nil
+		}
+
+		if err := session.conn.negotiator.sendCommand(command); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+func (session *internalTerminalSession) status() {
+	if nil != session.conn {
+		fmt.Fprintf(session.stdout, "Connected to %s.\r\n", session.conn.RemoteAddr())
+	}
+
+	if session.characterMode() {
+		fmt.Fprint(session.stdout, "Operating in character at a time mode.\r\n")
+	} else {
+		fmt.Fprint(session.stdout, "Operating in line by line mode.\r\n")
+	}
+
+	if session.noEscape {
+		fmt.Fprint(session.stdout, "No escape character.\r\n")
+	} else {
+		fmt.Fprintf(session.stdout, "Escape character is '%s'.\r\n", visibleByte(session.escape))
+	}
+
+	if session.crlf {
+		fmt.Fprint(session.stdout, "Will send carriage returns as telnet <CR><LF>.\r\n")
+	} else {
+		fmt.Fprint(session.stdout, "Will send carriage returns as telnet <CR><NUL>.\r\n")
+	}
+
+	if nil != session.editor {
+		fmt.Fprint(session.stdout, "Local line editing is on (in line mode).\r\n")
+	}
+}
+
+
+func (session *internalTerminalSession) mode(args []string) error {
+	if len(args) <= 0 || "?" == args[0] {
+		fmt.Fprint(session.stdout,
+			"character   characters are sent as they are typed (and the server echoes them)\r\n" +
+			"line        lines are edited (and echoed) locally, and sent a line at a time\r\n")
+		return nil
+	}
+
+	if nil == session.conn {
+		fmt.Fprint(session.stdout, "?Not connected\r\n")
+		return nil
+	}
+
+	negotiator := session.conn.negotiator
+
+	switch args[0] {
+	case "character", "char":
+		if err := negotiator.offerRemote(optionSuppressGoAhead); nil != err {
+			return err
+		}
+		return negotiator.offerRemote(optionEcho)
+	case "line":
+		return negotiator.refuseRemote(optionEcho)
+	default:
+		fmt.Fprintf(session.stdout, "?Unknown mode '%s'\r\n", args[0])
+	}
+
+	return nil
+}
+
+
+func (session *internalTerminalSession) toggle(args []string) {
+	if len(args) <= 0 || "?" == args[0] {
+		fmt.Fprint(session.stdout, "crlf        sending carriage returns as telnet <CR><LF>\r\n")
+		return
+	}
+
+	switch args[0] {
+	case "crlf":
+		session.crlf = !session.crlf
+		if session.crlf {
+			fmt.Fprint(session.stdout, "Will send carriage returns as telnet <CR><LF>.\r\n")
+		} else {
+			fmt.Fprint(session.stdout, "Will send carriage returns as telnet <CR><NUL>.\r\n")
+		}
+	default:
+		fmt.Fprintf(session.stdout, "?Unknown toggle argument '%s'\r\n", args[0])
+	}
+}
+
+
+// visibleByte returns 'b' in a form that can be shown; e.g., "^]" for Ctrl-].
+func visibleByte(b byte) string {
+	switch {
+	case b < 0x20:
+		return "^" + string(rune(b + 0x40))
+	case 0x7F == b:
+		return "^?"
+	default:
+		return string(rune(b))
+	}
+}
+
+
+// translateTerminalInput appends what was typed at the terminal, 'p', to 'dst', translating
+// line endings to TELNET ones.
+//
+// In raw mode, the Enter key gives a CR, which becomes CR NUL (or CR LF, if 'crlf' is true).
+// In line mode, a line ends with a LF, which becomes CR LF.
+func translateTerminalInput(dst []byte, p []byte, raw bool, crlf bool) []byte {
+	for _, b := range p {
+		switch {
+		case raw && '\r' == b && crlf:
+			dst = append(dst, '\r', '\n')
+		case raw && '\r' == b:
+			dst = append(dst, '\r', 0)
+		case !raw && '\n' == b:
+			dst = append(dst, '\r', '\n')
+		default:
+			dst = append(dst, b)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,197 @@
+package telnet
+
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"testing"
+)
+
+
+func TestTerminalSessionEscape(t *testing.T) {
+
+	tests := []struct{
+		Typed    []string
+		Config   StandardCallerConfig
+		Expected string
+	}{
+		{
+			Typed:    []string{"ab\n", "c\n"},
+			Expected: "ab\r\nc\r\n",
+		},
+		{
+			Typed:    []string{"ab\x1d", "send ayt\n", "c\n"},
+			Expected: "ab\xff\xf6c\r\n", // ... IAC AYT ...
+		},
+		{
+			Typed:    []string{"a\x1d", "send brk ip ao\n", "b\n"},
+			Expected: "a\xff\xf3\xff\xf4\xff\xf5b\r\n", // ... IAC BRK IAC IP IAC AO ...
+		},
+		{
+			Typed:    []string{"a\x1d", "send escape\n"},
+			Expected: "a\x1d",
+		},
+		{
+			Typed:    []string{"a\x1d", "\n", "b\n"},
+			Expected: "ab\r\n",
+		},
+		{
+			Typed:    []string{"a\x1d", "close\n", "b\n"},
+			Expected: "a",
+		},
+		{
+			Typed:    []string{"a\x1d", "status\n", "b\n"},
+			Expected: "ab\r\n",
+		},
+		{
+			Typed:    []string{"a\x01", "close\n", "b\n"},
+			Config:   StandardCallerConfig{Escape:0x01},
+			Expected: "a",
+		},
+		{
+			Typed:    []string{"a\x1db\n"},
+			Config:   StandardCallerConfig{NoEscape:true},
+			Expected: "a\x1db\r\n",
+		},
+
+
+
+		{
+			Typed:    []string{"a\n"},
+			Config:   StandardCallerConfig{Commands:[]string{"send ayt", "toggle crlf"}},
+			Expected: "\xff\xf6a\r\n", // IAC AYT ...
+		},
+		{
+			Typed:    []string{"a\n"},
+			Config:   StandardCallerConfig{Commands:[]string{"close"}},
+			Expected: "",
+		},
+
+
+
+		{
+			Typed:    []string{"abx\x7fc\r", "\x1b[A!\r"},
+			Config:   StandardCallerConfig{LineEditing:true},
+			Expected: "abc\r\nabc!\r\n",
+		},
+		{
+			Typed:    []string{"ab\x03"},
+			Config:   StandardCallerConfig{LineEditing:true},
+			Expected: "\xff\xf4", // IAC IP
+		},
+		{
+			Typed:    []string{"ab\x1d", "send ayt\n", "c\r"},
+			Config:   StandardCallerConfig{LineEditing:true},
+			Expected: "\xff\xf6abc\r\n", // IAC AYT ...
+		},
+		{
+			Typed:    []string{"ab\r", "\x04", "c\r"},
+			Config:   StandardCallerConfig{LineEditing:true},
+			Expected: "ab\r\n",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		clientSide, serverSide := net.Pipe()
+		conn := newClientConn(clientSide)
+
+		stdinReader, stdinWriter, err := os.Pipe()
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		received := make(chan []byte, 1)
+		go func() {
+			p, _ := ioutil.ReadAll(serverSide)
+			received <- p
+		}()
+
+		go func(typed []string) {
+			for _, s := range typed {
+				io.WriteString(stdinWriter, s)
+				time.Sleep(5 * time.Millisecond)
+			}
+			stdinWriter.Close()
+		}(test.Typed)
+
+		session := newTerminalSession(stdinReader, ioutil.Discard, conn, conn, test.Config)
+		if err := session.readStdin(); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+
+		clientSide.Close()
+		stdinReader.Close()
+
+		if expected, actual := test.Expected, string(<-received); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestVisibleByte(t *testing.T) {
+
+	tests := []struct{
+		Byte     byte
+		Expected string
+	}{
+		{Byte: 0x1D, Expected: "^]"},
+		{Byte: 0x01, Expected: "^A"},
+		{Byte: 0x7F, Expected: "^?"},
+		{Byte: '~',  Expected: "~"},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, visibleByte(test.Byte); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestTranslateTerminalInput(t *testing.T) {
+
+	tests := []struct{
+		Bytes    []byte
+		Raw      bool
+		CRLF     bool
+		Expected []byte
+	}{
+		{
+			Bytes:    []byte("ls -l\n"),
+			Raw:      false,
+			Expected: []byte("ls -l\r\n"),
+		},
+		{
+			Bytes:    []byte("ls -l\r"),
+			Raw:      true,
+			Expected: []byte("ls -l\r\x00"),
+		},
+		{
+			Bytes:    []byte("ls -l\r"),
+			Raw:      true,
+			CRLF:     true,
+			Expected: []byte("ls -l\r\n"),
+		},
+		{
+			Bytes:    []byte{'q', 0x03, 0x1b, '[', 'A'}, // 'q' Ctrl-C Up-Arrow
+			Raw:      true,
+			Expected: []byte{'q', 0x03, 0x1b, '[', 'A'},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := string(test.Expected), string(translateTerminalInput(nil, test.Bytes, test.Raw, test.CRLF)); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package telnet
+
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+var errTranscriptLine = errors.New("Bad transcript line.")
+
+
+// A TranscriptFormat is the format of a Transcript.
+type TranscriptFormat int
+
+const (
+	// TranscriptText is plain text: what was shown on the terminal. That is, the data the server
+	// sent (with TELNET commands stripped), and the data sent to it that was echoed locally (i.e.,
+	// while the server did not do the ECHO option). Data sent while the server did the ECHO option
+	// is left out, since the server echoes whatever should be seen.
+	TranscriptText TranscriptFormat = iota
+
+	// TranscriptTimestamped has a line for each piece of data sent or received; each line has a
+	// timestamp (in RFC 3339 format, with nanoseconds), a marker, and the data (Go-quoted):
+	//
+	//	2006-01-02T15:04:05.999999999Z recv "login: "
+	//	2006-01-02T15:04:05.999999999Z send "joe\r\n"
+	//	2006-01-02T15:04:05.999999999Z send-hidden "******\r\n"
+	//
+	// Where the marker is:
+	//
+	// • "recv", for data received;
+	//
+	// • "send", for data sent, and echoed locally; or
+	//
+	// • "send-hidden", for data sent while the server did the ECHO option. (Which the server either
+	// echoed, as received data, or left hidden, such as a password.)
+	//
+	// A transcript in this format can be played back with ReplayTranscript.
+	TranscriptTimestamped
+)
+
+
+// A Transcript logs a session, such as to a file. It can be given to a Client, StandardCaller
+// (with NewStandardCaller), or a Conn (with ConnConfig).
+//
+// For example:
+//
+//	file, err := os.OpenFile("session.log", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+//	if nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	defer file.Close()
+//
+//	client := telnet.Client{
+//		Caller: telnet.StandardCaller,
+//		Transcript: &telnet.Transcript{
+//			Writer:          file,
+//			Format:          telnet.TranscriptTimestamped,
+//			MaskHiddenInput: true,
+//		},
+//	}
+//
+// If writing to the Writer fails, then the session still goes on; Err returns the (first) error.
+type Transcript struct {
+	Writer io.Writer
+	Format TranscriptFormat
+
+	// MaskHiddenInput, if true, masks (with '*') the data sent while the server does the ECHO
+	// option; such as a password typed at a prompt that does not echo. (Line endings are kept.)
+	// The password a Client logs in with is masked too.
+	MaskHiddenInput bool
+
+	mutex sync.Mutex
+	err   error
+}
+
+
+// Err returns the first error there was writing the transcript; or nil if there was none.
+func (transcript *Transcript) Err() error {
+	transcript.mutex.Lock()
+	defer transcript.mutex.Unlock()
+
+	return transcript.err
+}
+
+
+// received logs data received from the server.
+func (transcript *Transcript) received(p []byte) error {
+	return transcript.log("recv", p)
+}
+
+
+// sent logs data sent to the server. 'hidden' is true if the server does the ECHO option; and
+// 'secret' is true if the data should be masked (if MaskHiddenInput) even so. (Such as a password.)
+func (transcript *Transcript) sent(p []byte, hidden bool, secret bool) error {
+	if !hidden && !secret {
+		return transcript.log("send", p)
+	}
+
+	if transcript.MaskHiddenInput {
+		p = maskTranscriptData(p)
+	}
+
+	return transcript.log("send-hidden", p)
+}
+
+
+func (transcript *Transcript) log(marker string, p []byte) error {
+	if 0 == len(p) {
+		return nil
+	}
+
+	transcript.mutex.Lock()
+	defer transcript.mutex.Unlock()
+
+	// Once writing the transcript fails, it is not tried again. (Err returns the error.)
+	if nil != transcript.err || nil == transcript.Writer {
+		return nil
+	}
+
+	var err error
+	switch transcript.Format {
+	case TranscriptTimestamped:
+		_, err = fmt.Fprintf(transcript.Writer, "%s %s %q\n", time.Now().UTC().Format(time.RFC3339Nano), marker, p)
+	default:
+		if "send-hidden" != marker {
+			_, err = transcript.Writer.Write(bytes.Replace(p, []byte{0}, nil, -1))
+		}
+	}
+
+	transcript.err = err
+	return err
+}
+
+
+// maskTranscriptData returns 'p' with everything but the line endings replaced with '*'.
+func maskTranscriptData(p []byte) []byte {
+	masked := make([]byte, len(p))
+	for i, b := range p {
+		switch b {
+		case '\r', '\n', 0:
+			masked[i] = b
+		default:
+			masked[i] = '*'
+		}
+	}
+
+	return masked
+}
+
+
+// ReplayTranscript plays back a transcript in the TranscriptTimestamped format, from 'r', by
+// writing what was shown on the terminal (i.e., the "recv" and "send" data) to 'w'.
+//
+// 'speed' is how fast to play it back: 1 is as fast as it happened, 2 is twice as fast, and
+// so on. If 'speed' is zero (or less), then it is played back without any pauses.
+func ReplayTranscript(w io.Writer, r io.Reader, speed float64) error {
+
+	var previous time.Time
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if "" == line {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 {
+			return errTranscriptLine
+		}
+
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if nil != err {
+			return err
+		}
+
+		data, err := strconv.Unquote(fields[2])
+		if nil != err {
+			return err
+		}
+
+		switch fields[1] {
+		case "recv", "send":
+			// Shown.
+		case "send-hidden":
+			continue
+		default:
+			return errTranscriptLine
+		}
+
+		if 0 < speed && !previous.IsZero() {
+			if pause := timestamp.Sub(previous); 0 < pause {
+				time.Sleep(time.Duration(float64(pause) / speed))
+			}
+		}
+		previous = timestamp
+
+		if _, err := io.WriteString(w, data); nil != err {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,172 @@
+package telnet
+
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+
+	"testing"
+)
+
+
+// transcriptTestSession has the server send "hello", the client send "ls", the server turn on
+// ECHO and ask for a password, and the client send "secret"; logging it all to 'transcript'.
+func transcriptTestSession(t *testing.T, transcript *Transcript) {
+
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+
+	conn := NewConn(clientSide, &ConnConfig{Transcript:transcript})
+
+	go io.Copy(ioutil.Discard, serverSide)
+	go func() {
+		serverSide.Write([]byte("hello\r\n"))
+		serverSide.Write([]byte("\xff\xfb\x01Password: ")) // IAC WILL ECHO "Password: "
+	}()
+
+	p := make([]byte, 64)
+
+	if _, err := conn.Read(p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if _, err := conn.Write([]byte("ls\r\n")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if _, err := conn.Read(p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if _, err := conn.Write([]byte("secret\r\n")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
+
+
+func TestTranscript(t *testing.T) {
+
+	tests := []struct{
+		Format          TranscriptFormat
+		MaskHiddenInput bool
+		Expected        string
+	}{
+		{
+			Format:   TranscriptText,
+			Expected: "hello\r\nls\r\nPassword: ",
+		},
+		{
+			Format:          TranscriptText,
+			MaskHiddenInput: true,
+			Expected:        "hello\r\nls\r\nPassword: ",
+		},
+		{
+			Format:   TranscriptTimestamped,
+			Expected: `T recv "hello\r\n"` + "\n" +
+			          `T send "ls\r\n"` + "\n" +
+			          `T recv "Password: "` + "\n" +
+			          `T send-hidden "secret\r\n"` + "\n",
+		},
+		{
+			Format:          TranscriptTimestamped,
+			MaskHiddenInput: true,
+			Expected:        `T recv "hello\r\n"` + "\n" +
+			                 `T send "ls\r\n"` + "\n" +
+			                 `T recv "Password: "` + "\n" +
+			                 `T send-hidden "******\r\n"` + "\n",
+		},
+	}
+
+
+	timestamp := regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z `)
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		transcript := Transcript{
+			Writer:          &buffer,
+			Format:          test.Format,
+			MaskHiddenInput: test.MaskHiddenInput,
+		}
+
+		transcriptTestSession(t, &transcript)
+
+		if err := transcript.Err(); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, timestamp.ReplaceAllString(buffer.String(), "T "); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestReplayTranscript(t *testing.T) {
+
+	tests := []struct{
+		Transcript string
+		Expected   string
+		Err        bool
+	}{
+		{
+			Transcript: "",
+			Expected:   "",
+		},
+		{
+			Transcript: `2020-01-02T03:04:05.000000001Z recv "hello\r\n"` + "\n" +
+			            `2020-01-02T03:04:05.000000002Z send "ls\r\n"` + "\n" +
+			            `2020-01-02T03:04:05.000000003Z recv "Password: "` + "\n" +
+			            `2020-01-02T03:04:05.000000004Z send-hidden "******\r\n"` + "\n" +
+			            `2020-01-02T03:04:05.000000005Z recv "\r\n$ "` + "\n",
+			Expected:   "hello\r\nls\r\nPassword: \r\n$ ",
+		},
+		{
+			Transcript: `2020-01-02T03:04:05Z recv "a b c"` + "\n",
+			Expected:   "a b c",
+		},
+		{
+			Transcript: `2020-01-02T03:04:05Z recv`,
+			Err:        true,
+		},
+		{
+			Transcript: `yesterday recv "hello"`,
+			Err:        true,
+		},
+		{
+			Transcript: `2020-01-02T03:04:05Z sent "hello"`,
+			Err:        true,
+		},
+		{
+			Transcript: `2020-01-02T03:04:05Z recv hello`,
+			Err:        true,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		err := ReplayTranscript(&buffer, strings.NewReader(test.Transcript), 0)
+		if test.Err {
+			if nil == err {
+				t.Errorf("For test #%d, expected an error, but did not actually get one.", testNumber)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
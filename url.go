@@ -0,0 +1,157 @@
+package telnet
+
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+
+var (
+	errURLScheme = errors.New("Unsupported URL scheme; must be \"telnet\" or \"telnets\".")
+	errURLHost   = errors.New("URL is missing the host.")
+	errURLPath   = errors.New("TELNET URL cannot have a path, query or fragment.")
+)
+
+
+// A URL is a parsed telnet:// or telnets:// URL (RFC 4248).
+//
+// For example:
+//
+//	telnet://joeblow@example.net:2323/
+//	telnets://[2001:db8::1]/
+type URL struct {
+	Scheme   string // "telnet" or "telnets".
+	User     string // optional.
+	Password string // optional.
+	Host     string // host name or IP address. (An IPv6 address is without the square brackets.)
+	Port     string // "23" for "telnet", or "992" for "telnets", if the URL did not have one.
+}
+
+
+// ParseURL parses a telnet:// or telnets:// URL.
+//
+// If the URL does not have a port, then the default port is used: 23 for "telnet", and
+// 992 for "telnets".
+func ParseURL(rawurl string) (*URL, error) {
+
+	u, err := url.Parse(rawurl)
+	if nil != err {
+		return nil, err
+	}
+
+	var defaultPort string
+
+	switch u.Scheme {
+	case "telnet":
+		defaultPort = "23"
+	case "telnets":
+		defaultPort = "992"
+	default:
+		return nil, errURLScheme
+	}
+
+	if "" != u.Opaque || ("" != u.Path && "/" != u.Path) || "" != u.RawQuery || "" != u.Fragment {
+		return nil, errURLPath
+	}
+
+	host := u.Hostname()
+	if "" == host {
+		return nil, errURLHost
+	}
+
+	port := u.Port()
+	if "" == port {
+		port = defaultPort
+	}
+
+	telnetURL := URL{
+		Scheme: u.Scheme,
+		Host:   host,
+		Port:   port,
+	}
+
+	if nil != u.User {
+		telnetURL.User = u.User.Username()
+		telnetURL.Password, _ = u.User.Password()
+	}
+
+	return &telnetURL, nil
+}
+
+
+// Addr returns the "host:port" address of the URL, suitable for DialTo, DialToTLS, or Dialer.Dial.
+func (u *URL) Addr() string {
+	return net.JoinHostPort(u.Host, u.Port)
+}
+
+
+// TLS reports whether the URL is for a (secure) TELNETS connection.
+func (u *URL) TLS() bool {
+	return "telnets" == u.Scheme
+}
+
+
+// String returns the URL as a string. (The password is left out.)
+func (u *URL) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(u.Scheme)
+	builder.WriteString("://")
+	if "" != u.User {
+		builder.WriteString(url.User(u.User).String())
+		builder.WriteByte('@')
+	}
+	builder.WriteString(u.Addr())
+	builder.WriteByte('/')
+
+	return builder.String()
+}
+
+
+// DialURL makes a TELNET (or TELNETS) client connection to what the telnet:// (or telnets://)
+// URL 'rawurl' specifies.
+//
+// If the URL has a user, then it is sent to the server (if it asks for it) as the NEW-ENVIRON
+// "USER" variable.
+//
+// For example:
+//
+//	conn, err := telnet.DialURL("telnet://joeblow@example.net/")
+func DialURL(rawurl string) (*Conn, error) {
+	var dialer Dialer
+
+	return dialer.DialURL(context.Background(), rawurl)
+}
+
+
+// DialURL is like the DialURL func, but uses the Dialer's options and the context 'ctx'.
+//
+// For a telnets:// URL, the Dialer's TLSConfig is used (or a default one if it is nil).
+// For a telnet:// URL, the Dialer's TLSConfig is ignored.
+func (dialer *Dialer) DialURL(ctx context.Context, rawurl string) (*Conn, error) {
+
+	u, err := ParseURL(rawurl)
+	if nil != err {
+		return nil, err
+	}
+
+	d := *dialer
+	switch {
+	case !u.TLS():
+		d.TLSConfig = nil
+	case nil == d.TLSConfig:
+		d.TLSConfig = &tls.Config{}
+	}
+
+	config := ConnConfig{
+		Logger: dialer.Logger,
+		User:   u.User,
+	}
+
+	return d.dial(ctx, "tcp", u.Addr(), &config)
+}
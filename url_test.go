@@ -0,0 +1,113 @@
+package telnet
+
+
+import (
+	"testing"
+)
+
+
+func TestParseURL(t *testing.T) {
+
+	tests := []struct{
+		URL      string
+		Expected URL
+		Addr     string
+		String   string
+	}{
+		{
+			URL:      "telnet://example.net",
+			Expected: URL{Scheme:"telnet", Host:"example.net", Port:"23"},
+			Addr:     "example.net:23",
+			String:   "telnet://example.net:23/",
+		},
+		{
+			URL:      "telnet://joeblow@example.net:2323/",
+			Expected: URL{Scheme:"telnet", User:"joeblow", Host:"example.net", Port:"2323"},
+			Addr:     "example.net:2323",
+			String:   "telnet://joeblow@example.net:2323/",
+		},
+		{
+			URL:      "telnets://example.net/",
+			Expected: URL{Scheme:"telnets", Host:"example.net", Port:"992"},
+			Addr:     "example.net:992",
+			String:   "telnets://example.net:992/",
+		},
+		{
+			URL:      "telnets://[2001:db8::1]/",
+			Expected: URL{Scheme:"telnets", Host:"2001:db8::1", Port:"992"},
+			Addr:     "[2001:db8::1]:992",
+			String:   "telnets://[2001:db8::1]:992/",
+		},
+		{
+			URL:      "TELNET://admin:secret@[::1]:8023",
+			Expected: URL{Scheme:"telnet", User:"admin", Password:"secret", Host:"::1", Port:"8023"},
+			Addr:     "[::1]:8023",
+			String:   "telnet://admin@[::1]:8023/",
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		actual, err := ParseURL(test.URL)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected := test.Expected; expected != *actual {
+			t.Errorf("For test #%d, expected %#v, but actually got %#v.", testNumber, expected, *actual)
+			continue
+		}
+
+		if expected, actual := test.Addr, actual.Addr(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		if expected, actual := test.String, actual.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+
+func TestParseURLError(t *testing.T) {
+
+	tests := []struct{
+		URL      string
+		Expected error
+	}{
+		{
+			URL:      "ssh://example.net/",
+			Expected: errURLScheme,
+		},
+		{
+			URL:      "example.net:23",
+			Expected: errURLScheme,
+		},
+		{
+			URL:      "telnet:///",
+			Expected: errURLHost,
+		},
+		{
+			URL:      "telnet://example.net/path",
+			Expected: errURLPath,
+		},
+		{
+			URL:      "telnet://example.net/?q=1",
+			Expected: errURLPath,
+		},
+	}
+
+
+	for testNumber, test := range tests {
+
+		_, err := ParseURL(test.URL)
+		if expected, actual := test.Expected, err; expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+			continue
+		}
+	}
+}
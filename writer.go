@@ -4,3 +4,20 @@ package telnet
 type Writer interface {
 	Write([]byte) (int, error)
 }
+
+
+// A RecordWriter is a Writer that can also mark the end of a record, such as a prompt.
+//
+// The Writer passed to a Handler's ServeTELNET method (and to a Caller's CallTELNET
+// method) is a RecordWriter, and can be used like this:
+//
+//	if recordWriter, ok := w.(telnet.RecordWriter); ok {
+//		recordWriter.WriteEndOfRecord()
+//	}
+//
+// This sends an IAC EOR if END-OF-RECORD (RFC 885) was agreed to; else an IAC GA if
+// SUPPRESS-GO-AHEAD was not agreed to; else nothing.
+type RecordWriter interface {
+	Writer
+	WriteEndOfRecord() error
+}